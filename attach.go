@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// uploadFile uploads the file at path to the project, returning the markdown
+// that links to it
+func (c gitlabClient) uploadFile(ctx context.Context, project *gitlab.Project, path string) (string, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var file *gitlab.ProjectFile
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		file, resp, err = c.gitlab.Projects.UploadFile(project.ID, path, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not upload %s: %w", path, err)
+	}
+	return file.Markdown, nil
+}
+
+// uploadAttachments uploads each of paths to the project, returning the
+// markdown for those uploaded one per line. A file which fails to upload is
+// logged and left out rather than stopping the rest.
+func (c gitlabClient) uploadAttachments(ctx context.Context, project *gitlab.Project, paths []string) string {
+	markdown := []string{}
+	for _, path := range paths {
+		if c.dryRun {
+			infof("Dry run, not uploading %s", path)
+			continue
+		}
+		md, err := c.uploadFile(ctx, project, path)
+		if err != nil {
+			log.Printf("Not attaching %s: %s", path, describeErr(err))
+			continue
+		}
+		infof("uploaded: %s", path)
+		markdown = append(markdown, md)
+	}
+	return strings.Join(markdown, "\n")
+}
+
+// appendAttachments adds the markdown for attachments to the end of
+// description
+func appendAttachments(description, attachments string) string {
+	if attachments == "" {
+		return description
+	}
+	description = strings.TrimRight(description, "\n")
+	if description == "" {
+		return attachments
+	}
+	return description + "\n\n" + attachments
+}
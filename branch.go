@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// maxSlugLength keeps branch names made from long titles manageable
+const maxSlugLength = 50
+
+// issueBranchName is the branch for working on issue, named like gitlab's
+// own "Create branch" as {iid}-{slug of the title}
+func issueBranchName(issue *gitlab.Issue) string {
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, issue.Title)
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	slug = strings.Trim(slug, "-")
+	if runes := []rune(slug); len(runes) > maxSlugLength {
+		slug = strings.TrimRight(string(runes[:maxSlugLength]), "-")
+	}
+	return fmt.Sprintf("%d-%s", issue.IID, slug)
+}
+
+// createBranch creates branch in the project from its default branch
+func (c gitlabClient) createBranch(ctx context.Context, project *gitlab.Project, branch string) (*gitlab.Branch, error) {
+	if project.DefaultBranch == "" {
+		return nil, fmt.Errorf("could not create branch %s, %s has no default branch yet", branch, project.PathWithNamespace)
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var b *gitlab.Branch
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		b, resp, err = c.gitlab.Branches.CreateBranch(project.ID, &gitlab.CreateBranchOptions{
+			Branch: gitlab.String(branch),
+			Ref:    gitlab.String(project.DefaultBranch),
+		}, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create branch %s: %w", branch, err)
+	}
+	return b, nil
+}
+
+// checkoutBranch checks out a local branch tracking branch on remoteName,
+// fetching it when its commit is not in the repository yet.
+func checkoutBranch(repo *git.Repository, remoteName, branch, commit string) error {
+	hash := plumbing.NewHash(commit)
+	if _, err := repo.CommitObject(hash); err != nil {
+		err = repo.Fetch(&git.FetchOptions{
+			RemoteName: remoteName,
+			RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("refs/heads/%s:refs/remotes/%s/%s", branch, remoteName, branch))},
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return fmt.Errorf("could not fetch %s: %w", branch, err)
+		}
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("could not get worktree: %w", err)
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branch),
+		Hash:   hash,
+		Create: true,
+		Keep:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("could not check out %s: %w", branch, err)
+	}
+	err = repo.CreateBranch(&config.Branch{
+		Name:   branch,
+		Remote: remoteName,
+		Merge:  plumbing.NewBranchReferenceName(branch),
+	})
+	if err != nil {
+		return fmt.Errorf("could not track %s/%s: %w", remoteName, branch, err)
+	}
+	return nil
+}
+
+// startIssueBranch creates the branch for issue in the project and checks it
+// out in repo.
+func (c gitlabClient) startIssueBranch(ctx context.Context, repo *git.Repository, remoteName string, project *gitlab.Project, issue *gitlab.Issue) (string, error) {
+	name := issueBranchName(issue)
+	if c.dryRun {
+		return name, nil
+	}
+	branch, err := c.createBranch(ctx, project, name)
+	if err != nil {
+		return "", err
+	}
+	err = checkoutBranch(repo, remoteName, name, branch.Commit.ID)
+	if err != nil {
+		return "", fmt.Errorf("%w, the branch was created on gitlab so try git fetch && git checkout %s", err, name)
+	}
+	return name, nil
+}
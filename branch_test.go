@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func TestIssueBranchName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Broken build", want: "12-broken-build"},
+		{title: "  Fix: crash -- on save!  ", want: "12-fix-crash-on-save"},
+		{title: "Crème brûlée: ÇA marche?", want: "12-crème-brûlée-ça-marche"},
+		{title: "日本語 のタイトル", want: "12-日本語-のタイトル"},
+		{title: "v1.2 → v2.0", want: "12-v1-2-v2-0"},
+		{title: strings.Repeat("a", 49) + " b c", want: "12-" + strings.Repeat("a", 49)},
+		{title: strings.Repeat("é", 60), want: "12-" + strings.Repeat("é", maxSlugLength)},
+	}
+	for _, tt := range tests {
+		if got := issueBranchName(&gitlab.Issue{IID: 12, Title: tt.title}); got != tt.want {
+			t.Errorf("issueBranchName(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// projectCacheTTL is how long a cached project lookup is trusted for
+const projectCacheTTL = 24 * time.Hour
+
+type projectCacheEntry struct {
+	ID       int       `json:"id"`
+	Path     string    `json:"path"`
+	CachedAt time.Time `json:"cached_at"`
+	// Selected is set when the project was selected by the user, as the
+	// remote could not be resolved, so the entry does not expire
+	Selected bool `json:"selected,omitempty"`
+	// Labels are the labels last given to an issue created in the project
+	Labels []string `json:"labels,omitempty"`
+}
+
+// projectCache maps a project path on an instance, as instance/path so no
+// credentials are stored, to the project it was resolved to. It is stored in
+// ~/.cache/gitlab/projects.json
+type projectCache map[string]projectCacheEntry
+
+// projectCacheKey is the key of a project path on instance in projectCache
+func projectCacheKey(instance, projectPath string) string {
+	return instance + "/" + projectPath
+}
+
+// cacheInstance names the instance served at baseURL in the project cache,
+// as the API's host and any path prefix, so projects on different instances
+// never share an entry whatever remote or flag they were found from
+func cacheInstance(baseURL *url.URL) string {
+	return baseURL.Host + strings.TrimSuffix(strings.TrimRight(baseURL.Path, "/"), "/api/v4")
+}
+
+// cacheDir is ~/.cache/gitlab, holding what is cached between runs
+func cacheDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home-dir: %w", err)
+	}
+	return filepath.Join(home, ".cache", "gitlab"), nil
+}
+
+func projectCacheFile() (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "projects.json"), nil
+}
+
+func loadProjectCache() (projectCache, error) {
+	cache := projectCache{}
+	cacheFile, err := projectCacheFile()
+	if err != nil {
+		return cache, err
+	}
+	b, err := ioutil.ReadFile(cacheFile)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, fmt.Errorf("could not read project cache %q: %w", cacheFile, err)
+	}
+	err = json.Unmarshal(b, &cache)
+	if err != nil {
+		return projectCache{}, fmt.Errorf("could not parse project cache %q: %w", cacheFile, err)
+	}
+	return cache, nil
+}
+
+func (p projectCache) save() error {
+	cacheFile, err := projectCacheFile()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(cacheFile), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not make dir %q: %w", filepath.Dir(cacheFile), err)
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode project cache: %w", err)
+	}
+	err = ioutil.WriteFile(cacheFile, b, 0600)
+	if err != nil {
+		return fmt.Errorf("could not write project cache %q: %w", cacheFile, err)
+	}
+	return nil
+}
+
+// getCachedProject resolves the project at projectPath on instance, using the
+// project ID cached from a previous lookup when it is fresh.
+func (c gitlabClient) getCachedProject(ctx context.Context, instance, projectPath string) (*gitlab.Project, error) {
+	remoteURL := projectCacheKey(instance, projectPath)
+	cache, err := loadProjectCache()
+	if err != nil {
+		log.Printf("Ignoring project cache: %s", err)
+	}
+	entry, ok := cache[remoteURL]
+	if ok && (entry.Selected || time.Since(entry.CachedAt) < projectCacheTTL) {
+		ctx, cancel := c.requestContext(ctx)
+		defer cancel()
+		project, _, err := c.gitlab.Projects.GetProject(entry.ID, nil, gitlab.WithContext(ctx))
+		if err == nil {
+			return project, nil
+		}
+		log.Printf("Cached project %s could not be fetched: %s", entry.Path, describeErr(err))
+	}
+	project, selected, err := c.findProject(ctx, projectPath)
+	if err != nil {
+		return nil, err
+	}
+	cache[remoteURL] = projectCacheEntry{ID: project.ID, Path: project.PathWithNamespace, CachedAt: time.Now(), Selected: selected, Labels: entry.Labels}
+	err = cache.save()
+	if err != nil {
+		log.Printf("Could not cache project: %s", err)
+	}
+	return project, nil
+}
+
+// recallLabels returns the labels last used for the project at projectPath
+// on instance
+func recallLabels(instance, projectPath string) []string {
+	cache, err := loadProjectCache()
+	if err != nil {
+		log.Printf("Ignoring project cache: %s", err)
+	}
+	return cache[projectCacheKey(instance, projectPath)].Labels
+}
+
+// rememberLabels stores labels as the last used for the project at
+// projectPath on instance
+func rememberLabels(instance, projectPath string, project *gitlab.Project, labels []string) error {
+	cache, err := loadProjectCache()
+	if err != nil {
+		return err
+	}
+	key := projectCacheKey(instance, projectPath)
+	entry, ok := cache[key]
+	if !ok || entry.ID != project.ID {
+		// not trusted as a project lookup without CachedAt
+		entry = projectCacheEntry{ID: project.ID, Path: project.PathWithNamespace}
+	}
+	entry.Labels = labels
+	cache[key] = entry
+	return cache.save()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// isolateHome points the home and config dirs at a temporary dir for the
+// test, so caches and local templates are not read or written in the real
+// ones
+func isolateHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	for _, env := range []string{"HOME", "XDG_CONFIG_HOME", "GITLAB_TEMPLATE_DIR"} {
+		old, ok := os.LookupEnv(env)
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		})
+	}
+	os.Setenv("HOME", home)
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("GITLAB_TEMPLATE_DIR")
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+	return home
+}
+
+func TestCacheInstance(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+	}{
+		{baseURL: "https://gitlab.com/api/v4", want: "gitlab.com"},
+		{baseURL: "https://gitlab.example.com:8443/api/v4", want: "gitlab.example.com:8443"},
+		{baseURL: "https://example.com/gitlab/api/v4", want: "example.com/gitlab"},
+		{baseURL: "https://example.com/gitlab/api/v4/", want: "example.com/gitlab"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.baseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cacheInstance(u); got != tt.want {
+			t.Errorf("cacheInstance(%s) = %q, want %q", tt.baseURL, got, tt.want)
+		}
+	}
+	// -project with -base-url must not share the gitlab.com entry
+	self, _ := url.Parse("https://gitlab.example.com/api/v4")
+	com, _ := url.Parse("https://gitlab.com/api/v4")
+	if projectCacheKey(cacheInstance(self), "g/p") == projectCacheKey(cacheInstance(com), "g/p") {
+		t.Error("projects on different instances share a cache key")
+	}
+}
+
+func TestGetCachedProject(t *testing.T) {
+	isolateHome(t)
+	byID := 0
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/7":
+			byID++
+			writeJSON(t, w, "", &gitlab.Project{ID: 7, PathWithNamespace: "g/cached"})
+		case "/api/v4/projects/g%2Ffresh", "/api/v4/projects/g%2Fstale", "/api/v4/projects/g%2Fselected":
+			writeJSON(t, w, "", &gitlab.Project{ID: 8, PathWithNamespace: "g/looked-up"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	now := time.Now()
+	cache := projectCache{
+		projectCacheKey("gitlab.com", "g/fresh"):    {ID: 7, CachedAt: now.Add(-time.Hour)},
+		projectCacheKey("gitlab.com", "g/stale"):    {ID: 7, CachedAt: now.Add(-projectCacheTTL - time.Hour)},
+		projectCacheKey("gitlab.com", "g/selected"): {ID: 7, CachedAt: now.Add(-30 * projectCacheTTL), Selected: true},
+	}
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path   string
+		wantID int
+	}{
+		{path: "g/fresh", wantID: 7},
+		{path: "g/stale", wantID: 8},
+		{path: "g/selected", wantID: 7},
+	}
+	for _, tt := range tests {
+		byID = 0
+		project, err := client.getCachedProject(context.Background(), "gitlab.com", tt.path)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.path, err)
+		}
+		if project.ID != tt.wantID {
+			t.Errorf("%s resolved to project %d, want %d", tt.path, project.ID, tt.wantID)
+		}
+		if tt.wantID == 7 && byID != 1 {
+			t.Errorf("%s: cached project fetched %d times, want once", tt.path, byID)
+		}
+	}
+	cache, err := loadProjectCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := cache[projectCacheKey("gitlab.com", "g/stale")]; entry.ID != 8 || time.Since(entry.CachedAt) > time.Minute {
+		t.Errorf("stale entry was not refreshed, got %+v", entry)
+	}
+	if entry := cache[projectCacheKey("gitlab.com", "g/selected")]; !entry.Selected {
+		t.Errorf("selected entry lost Selected, got %+v", entry)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"os"
+)
+
+// inCI reports whether we are running in a gitlab CI job
+func inCI() bool {
+	return os.Getenv("GITLAB_CI") == "true"
+}
+
+// ciProject is CI_PROJECT_ID, the project a CI job runs in, or empty outside
+// of CI
+func ciProject() string {
+	if !inCI() {
+		return ""
+	}
+	return os.Getenv("CI_PROJECT_ID")
+}
+
+// jobTokenTransport authenticates requests with a CI job token in place of
+// the private token go-gitlab would send.
+type jobTokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t jobTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("PRIVATE-TOKEN")
+	req.Header.Set("JOB-TOKEN", t.token)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+type gitlabClient struct {
+	gitlab *gitlab.Client
+	// baseURL is the API root of the gitlab instance
+	baseURL *url.URL
+	// projectPath is the project worked on, relative to the instance, or its
+	// ID from CI_PROJECT_ID
+	projectPath string
+	// timeout bounds each operation against the gitlab API
+	timeout time.Duration
+	// retries is how many times a rate limited request is retried
+	retries int
+	// dryRun skips creating and updating issues, filling in the issue
+	// locally instead
+	dryRun bool
+	// dupCheck lists open issues like one written in the editor before
+	// creating it
+	dupCheck bool
+	// confirmCreate shows the fields of an issue written in the editor and
+	// asks before creating it
+	confirmCreate bool
+	// templateExtensions are the file extensions templates are found by
+	templateExtensions []string
+	// templateDir holds the local issue_templates and
+	// merge_request_templates, ~/.config/gitlab when empty
+	templateDir string
+	// templateProject is the path of a project sharing its templates with
+	// every project
+	templateProject string
+	// refreshTemplates fetches project templates instead of using those
+	// cached
+	refreshTemplates bool
+	// footer is added to the end of issues written in the editor
+	footer string
+	// jobToken is set when authenticated by CI_JOB_TOKEN, which can not look
+	// up the current user
+	jobToken bool
+	// offerProjects lets the user select the project on the terminal when
+	// the remote's can not be found. The project given by -project or
+	// CI_PROJECT_ID is never swapped for another.
+	offerProjects bool
+}
+
+// clientOptions are how to reach and use gitlab, from the flags
+type clientOptions struct {
+	// BaseURL is from -base-url, overriding the instance found otherwise
+	BaseURL string
+	// Project is from -project, in place of the project of the remote
+	Project string
+	// Remote is the name of the git remote the project is found from
+	Remote string
+	// Token is from -token, overriding the config and environment
+	Token      string
+	CACertFile string
+	Insecure   bool
+	Proxy      string
+	Timeout    time.Duration
+	Retries    int
+	DryRun     bool
+	DupCheck   bool
+	// ConfirmCreate is cleared by -yes
+	ConfirmCreate bool
+	// RefreshTemplates ignores cached project templates
+	RefreshTemplates bool
+	// Debug logs every request to gitlab
+	Debug bool
+}
+
+// newClient sets up the client for the gitlab API of the instance found by
+// findBaseURL, finding the token for its host, falling back to CI_JOB_TOKEN,
+// and any local templates from cfg and the environment. repo may be nil when
+// options give the project.
+func newClient(cfg appConfig, repo *git.Repository, options clientOptions) (gitlabClient, error) {
+	baseURL, projectPath, err := findBaseURL(repo, options)
+	if err != nil {
+		return gitlabClient{}, err
+	}
+	token, err := getToken(options.Token, cfg, baseURL.Host)
+	if err != nil {
+		return gitlabClient{}, fmt.Errorf("failed to get token: %w", err)
+	}
+	caCert := options.CACertFile
+	if caCert == "" {
+		caCert = os.Getenv("GITLAB_CA_CERT")
+	}
+	if options.Insecure {
+		log.Println("WARNING: -insecure does not verify the certificate of gitlab, anyone between you and gitlab can read your token")
+	}
+	transport, err := newTransport(transportOptions{
+		CACertFile: caCert,
+		Insecure:   options.Insecure,
+		Proxy:      options.Proxy,
+	})
+	if err != nil {
+		return gitlabClient{}, err
+	}
+	jobToken := ""
+	if token == "" {
+		jobToken = os.Getenv("CI_JOB_TOKEN")
+	}
+	if token == "" && jobToken == "" {
+		return gitlabClient{}, errNoToken
+	}
+	if jobToken != "" {
+		infof("Using CI_JOB_TOKEN")
+		transport = jobTokenTransport{token: jobToken, base: transport}
+	}
+	if options.Debug {
+		transport = debugTransport{base: transport}
+	}
+	cli, err := gitlab.NewClient(token,
+		gitlab.WithBaseURL(baseURL.String()),
+		gitlab.WithCustomRetry(retryServerErrors),
+		gitlab.WithHTTPClient(&http.Client{Transport: transport}),
+	)
+	if err != nil {
+		return gitlabClient{}, fmt.Errorf("failed to create client: %w", err)
+	}
+	client := gitlabClient{
+		gitlab:             cli,
+		baseURL:            baseURL,
+		projectPath:        projectPath,
+		timeout:            options.Timeout,
+		retries:            options.Retries,
+		dryRun:             options.DryRun,
+		dupCheck:           options.DupCheck,
+		confirmCreate:      options.ConfirmCreate,
+		templateExtensions: defaultTemplateExtensions,
+		templateDir:        os.Getenv("GITLAB_TEMPLATE_DIR"),
+		jobToken:           jobToken != "",
+		footer:             cfg.Footer,
+		templateProject:    strings.Trim(cfg.TemplateProject, "/"),
+		refreshTemplates:   options.RefreshTemplates,
+		offerProjects:      options.Project == "" && ciProject() == "" && stdinIsTerminal(),
+	}
+	if len(cfg.TemplateExtensions) > 0 {
+		client.templateExtensions = cfg.TemplateExtensions
+	}
+	if client.templateDir == "" {
+		client.templateDir = cfg.TemplateDir
+	}
+	return client, nil
+}
+
+// findBaseURL finds the API root of the gitlab instance and the path of the
+// project relative to it. The project is -project, CI_PROJECT_ID in CI, or
+// else from the repository's remote. The instance is -base-url, GITLAB_URL,
+// CI_API_V4_URL for CI_PROJECT_ID, gitlab.host in the git config, or else the
+// remote's host.
+func findBaseURL(repo *git.Repository, options clientOptions) (*url.URL, string, error) {
+	scheme, host, projectPath := "https", defaultHost, options.Project
+	instanceURL := options.BaseURL
+	if instanceURL == "" {
+		instanceURL = os.Getenv("GITLAB_URL")
+	}
+	if projectPath == "" && ciProject() != "" {
+		projectPath = ciProject()
+		if instanceURL == "" {
+			instanceURL = os.Getenv("CI_API_V4_URL")
+		}
+	}
+	if projectPath == "" && repo == nil {
+		return nil, "", fmt.Errorf("error finding git repo in working directory: %w, please specify -project", errNoRepo)
+	}
+	if instanceURL == "" && repo != nil {
+		var err error
+		instanceURL, err = gitConfigInstanceURL(repo)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if projectPath == "" {
+		instanceHost := ""
+		if u, err := url.Parse(instanceURL); err == nil {
+			instanceHost = u.Host
+		}
+		var err error
+		scheme, host, projectPath, err = remoteProject(repo, options.Remote, instanceHost)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return apiURL(instanceURL, scheme, host, projectPath)
+}
+
+func (c gitlabClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, c.timeout)
+}
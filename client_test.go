@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// newTestClient is a client for a fake gitlab served by handler, whose API
+// is under /api/v4
+func newTestClient(t *testing.T, handler http.Handler) gitlabClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cli, err := gitlab.NewClient("token",
+		gitlab.WithBaseURL(server.URL+"/api/v4"),
+		gitlab.WithCustomRetry(retryServerErrors),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gitlabClient{
+		gitlab:             cli,
+		timeout:            5 * time.Second,
+		templateExtensions: defaultTemplateExtensions,
+	}
+}
+
+// writeJSON responds with v, setting X-Next-Page to nextPage when there is
+// another page
+func writeJSON(t *testing.T, w http.ResponseWriter, nextPage string, v interface{}) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if nextPage != "" {
+		w.Header().Set("X-Next-Page", nextPage)
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Error(err)
+	}
+}
+
+// setenv sets the environment variable key to value for the rest of the
+// test, unsetting it for an empty value
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+}
+
+// testRepo is a repository in memory with an origin remote at each of urls,
+// and gitlab.host set to gitlabHost when it is given
+func testRepo(t *testing.T, gitlabHost string, urls ...string) *git.Repository {
+	t.Helper()
+	repo, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) > 0 {
+		_, err = repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: urls})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gitlabHost != "" {
+		cfg, err := repo.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Raw.Section("gitlab").SetOption("host", gitlabHost)
+		if err := repo.SetConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestFindBaseURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		gitlabHost  string
+		remotes     []string
+		options     clientOptions
+		want        string
+		projectPath string
+	}{
+		{name: "https remote", remotes: []string{"https://gitlab.example.com/group/project.git"}, want: "https://gitlab.example.com/api/v4", projectPath: "group/project"},
+		{name: "ssh remote", remotes: []string{"git@gitlab.example.com:group/project.git"}, want: "https://gitlab.example.com/api/v4", projectPath: "group/project"},
+		{name: "http remote with port", remotes: []string{"http://gitlab.local:8080/g/p"}, want: "http://gitlab.local:8080/api/v4", projectPath: "g/p"},
+		{name: "several remote URLs", remotes: []string{"git@github.com:g/p.git", "git@gitlab.example.com:g/p.git"}, want: "https://gitlab.example.com/api/v4", projectPath: "g/p"},
+		{name: "gitlab.host", gitlabHost: "git.example.com", remotes: []string{"git@code.example.com:g/p.git"}, want: "https://git.example.com/api/v4", projectPath: "g/p"},
+		{name: "gitlab.host with prefix", gitlabHost: "https://example.com/gitlab", remotes: []string{"git@example.com:gitlab/g/p.git"}, want: "https://example.com/gitlab/api/v4", projectPath: "g/p"},
+		{name: "GITLAB_URL over gitlab.host", env: map[string]string{"GITLAB_URL": "https://env.example.com"}, gitlabHost: "git.example.com", remotes: []string{"git@code.example.com:g/p.git"}, want: "https://env.example.com/api/v4", projectPath: "g/p"},
+		{name: "-base-url over GITLAB_URL", env: map[string]string{"GITLAB_URL": "https://env.example.com"}, remotes: []string{"git@code.example.com:g/p.git"}, options: clientOptions{BaseURL: "https://flag.example.com/"}, want: "https://flag.example.com/api/v4", projectPath: "g/p"},
+		{name: "-project without a repository", options: clientOptions{Project: "g/p"}, want: "https://gitlab.com/api/v4", projectPath: "g/p"},
+		{name: "-project with GITLAB_URL", env: map[string]string{"GITLAB_URL": "https://env.example.com"}, options: clientOptions{Project: "g/p"}, want: "https://env.example.com/api/v4", projectPath: "g/p"},
+		{name: "CI project", env: map[string]string{"GITLAB_CI": "true", "CI_PROJECT_ID": "42", "CI_API_V4_URL": "https://ci.example.com/api/v4"}, want: "https://ci.example.com/api/v4", projectPath: "42"},
+		{name: "-project in CI", env: map[string]string{"GITLAB_CI": "true", "CI_PROJECT_ID": "42", "CI_API_V4_URL": "https://ci.example.com/api/v4"}, options: clientOptions{Project: "other/project"}, want: "https://gitlab.com/api/v4", projectPath: "other/project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateHome(t)
+			for _, key := range []string{"GITLAB_URL", "GITLAB_CI", "CI_PROJECT_ID", "CI_API_V4_URL"} {
+				setenv(t, key, tt.env[key])
+			}
+			var repo *git.Repository
+			if len(tt.remotes) > 0 {
+				repo = testRepo(t, tt.gitlabHost, tt.remotes...)
+			}
+			options := tt.options
+			options.Remote = "origin"
+			u, projectPath, err := findBaseURL(repo, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.String() != tt.want || projectPath != tt.projectPath {
+				t.Errorf("got %s, %q, want %s, %q", u, projectPath, tt.want, tt.projectPath)
+			}
+		})
+	}
+}
+
+func TestFindBaseURLNeedsProject(t *testing.T) {
+	isolateHome(t)
+	for _, key := range []string{"GITLAB_URL", "GITLAB_CI", "CI_PROJECT_ID", "CI_API_V4_URL"} {
+		setenv(t, key, "")
+	}
+	_, _, err := findBaseURL(nil, clientOptions{Remote: "origin"})
+	if !errors.Is(err, errNoRepo) {
+		t.Errorf("without a repository or -project got %v, want %v", err, errNoRepo)
+	}
+	if got := exitCode(err); got != 3 {
+		t.Errorf("exit code %d, want 3", got)
+	}
+	_, _, err = findBaseURL(testRepo(t, ""), clientOptions{Remote: "origin"})
+	if err == nil {
+		t.Error("found a project without a remote")
+	}
+}
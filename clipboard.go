@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand finds the command to write to the clipboard on this
+// platform.
+func clipboardCommand() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbcopy"), nil
+	case "windows":
+		return exec.Command("clip.exe"), nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-copy"); err == nil {
+			return exec.Command("wl-copy"), nil
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return exec.Command("xclip", "-selection", "clipboard"), nil
+	}
+	// WSL
+	if _, err := exec.LookPath("clip.exe"); err == nil {
+		return exec.Command("clip.exe"), nil
+	}
+	return nil, fmt.Errorf("no clipboard command found, install xclip or wl-copy")
+}
+
+func copyToClipboard(s string) error {
+	cmd, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(s)
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("could not copy to clipboard: %w", err)
+	}
+	return nil
+}
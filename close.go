@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// updateIssueState applies a state event, "close" or "reopen", to an issue
+func (c gitlabClient) updateIssueState(ctx context.Context, project *gitlab.Project, iid int, event string) (*gitlab.Issue, error) {
+	if c.dryRun {
+		infof("Dry run, not applying %s to issue #%d", event, iid)
+		return &gitlab.Issue{IID: iid, State: "dry run"}, nil
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	issue, _, err := c.gitlab.Issues.UpdateIssue(project.ID, iid, &gitlab.UpdateIssueOptions{StateEvent: gitlab.String(event)}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("could not %s issue #%d: %w", event, iid, err)
+	}
+	return issue, nil
+}
+
+// deleteIssueNote removes a comment from an issue
+func (c gitlabClient) deleteIssueNote(ctx context.Context, project *gitlab.Project, iid, noteID int) error {
+	if c.dryRun {
+		return nil
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	_, err := c.retry(ctx, func() (*gitlab.Response, error) {
+		return c.gitlab.Notes.DeleteIssueNote(project.ID, iid, noteID, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete comment on issue #%d: %w", iid, err)
+	}
+	return nil
+}
+
+// closeIssue is the "close" command. With -m the reason for closing is
+// commented first, and the comment is deleted again if the issue can then
+// not be closed, so a retry does not comment twice.
+func closeIssue(ctx context.Context, client gitlabClient, project *gitlab.Project, args []string) error {
+	flags := flag.NewFlagSet("close", flag.ExitOnError)
+	message := flags.String("m", "", "comment explaining why the issue is closed")
+	flags.Parse(args)
+	issue, err := client.getIssueFromArgs(ctx, project, flags.Args())
+	if err != nil {
+		return err
+	}
+	var note *gitlab.Note
+	if reason := strings.TrimSpace(*message); reason != "" {
+		note, err = client.createIssueNote(ctx, project, issue.IID, reason)
+		if err != nil {
+			return fmt.Errorf("%w, so the issue was not closed", err)
+		}
+	}
+	closed, err := client.updateIssueState(ctx, project, issue.IID, "close")
+	if err != nil {
+		if note == nil {
+			return err
+		}
+		deleteErr := client.deleteIssueNote(ctx, project, issue.IID, note.ID)
+		if deleteErr != nil {
+			return fmt.Errorf("%w, and the comment giving the reason was left at %s#note_%d: %v", err, issue.WebURL, note.ID, deleteErr)
+		}
+		return fmt.Errorf("%w, so the comment giving the reason was deleted again", err)
+	}
+	if client.dryRun {
+		return nil
+	}
+	log.Printf("closed: %s", closed.WebURL)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func TestDryRunChangesNothing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/1/issues/5", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("dry run made a %s request to %s", r.Method, r.URL.Path)
+		}
+		writeJSON(t, w, "", gitlab.Issue{ID: 105, IID: 5, Title: "Broken build", State: "opened"})
+	})
+	mux.HandleFunc("/api/v4/projects/1/issues/5/notes", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry run made a %s request to %s", r.Method, r.URL.Path)
+	})
+	client := newTestClient(t, mux)
+	client.dryRun = true
+	project := &gitlab.Project{ID: 1}
+	if err := closeIssue(context.Background(), client, project, []string{"-m", "fixed by !12", "5"}); err != nil {
+		t.Errorf("dry run close: %s", err)
+	}
+	if err := commentOnIssue(context.Background(), client, nil, project, []string{"-m", "looking at it", "5"}); err != nil {
+		t.Errorf("dry run comment: %s", err)
+	}
+}
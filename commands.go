@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// commandEnv is what a command runs against, found before it is run
+type commandEnv struct {
+	ctx     context.Context
+	client  gitlabClient
+	repo    *git.Repository
+	project *gitlab.Project
+	// user is the authenticated user, nil with a CI job token
+	user *gitlab.User
+	// open is set by -open or the open config
+	open bool
+}
+
+// command is a subcommand, run with the arguments following its name
+type command struct {
+	name  string
+	usage string
+	run   func(env commandEnv, args []string) error
+}
+
+// commands are the subcommands other than the default of creating an issue
+var commands = []command{
+	{
+		name:  "mr",
+		usage: "create a merge request from the current branch",
+		run: func(env commandEnv, args []string) error {
+			return createMergeRequest(env.ctx, env.client, env.repo, env.project)
+		},
+	},
+	{
+		name:  "list",
+		usage: "select from the open issues and print its URL",
+		run: func(env commandEnv, args []string) error {
+			return listOpenIssues(env.ctx, env.client, env.project, env.user, env.open, args)
+		},
+	},
+	{
+		name:  "mine",
+		usage: "select from the open issues assigned to you, like list -mine",
+		run: func(env commandEnv, args []string) error {
+			return listOpenIssues(env.ctx, env.client, env.project, env.user, env.open, append([]string{"-mine"}, args...))
+		},
+	},
+	{
+		name:  "show",
+		usage: "print the details of an issue",
+		run: func(env commandEnv, args []string) error {
+			return showIssue(env.ctx, env.client, env.project, args)
+		},
+	},
+	{
+		name:  "search",
+		usage: "select from the issues matching a term and print its details",
+		run: func(env commandEnv, args []string) error {
+			return searchIssues(env.ctx, env.client, env.project, args)
+		},
+	},
+	{
+		name:  "close",
+		usage: "close an issue",
+		run: func(env commandEnv, args []string) error {
+			return closeIssue(env.ctx, env.client, env.project, args)
+		},
+	},
+	{
+		name:  "comment",
+		usage: "comment on an issue",
+		run: func(env commandEnv, args []string) error {
+			return commentOnIssue(env.ctx, env.client, env.repo, env.project, args)
+		},
+	},
+	{
+		name:  "edit",
+		usage: "edit the title and description of an issue",
+		run: func(env commandEnv, args []string) error {
+			return editIssue(env.ctx, env.client, env.repo, env.project, args)
+		},
+	},
+}
+
+// issueCommand names the default command of creating an issue
+const issueCommand = "issue"
+
+// findCommand returns the command called name
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// commandNames are the names of every command offered to the user
+func commandNames() []string {
+	names := []string{issueCommand}
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// usage prints the commands and flags
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [flags] [command] [args]\n\nCommands:\n", os.Args[0])
+	fmt.Fprintf(w, "  %-8s %s\n", issueCommand, "create an issue, the default")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	flag.PrintDefaults()
+}
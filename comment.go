@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func (c gitlabClient) createIssueNote(ctx context.Context, project *gitlab.Project, iid int, body string) (*gitlab.Note, error) {
+	if c.dryRun {
+		infof("Dry run, not commenting on issue #%d", iid)
+		return &gitlab.Note{Body: body}, nil
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var note *gitlab.Note
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		note, resp, err = c.gitlab.Notes.CreateIssueNote(project.ID, iid, &gitlab.CreateIssueNoteOptions{Body: gitlab.String(body)}, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not comment on issue #%d: %w", iid, err)
+	}
+	return note, nil
+}
+
+// editComment gets a comment body from the user's editor, returning the path
+// of the file it was written in so it can be removed once posted.
+func editComment(repository *git.Repository, project *gitlab.Project, issue *gitlab.Issue) (path, body string, err error) {
+	commentChar := commentChars[0]
+	seed := bytes.Buffer{}
+	seed.WriteByte('\n')
+	fmt.Fprintf(&seed, "%c Commenting on #%d %s\n", commentChar, issue.IID, issue.Title)
+	fmt.Fprintf(&seed, "%c "+commentHelp+"\n", commentChar, commentChar)
+	path, content, err := editContent(repository, fmt.Sprintf("*_%s_%d_comment.md", project.Name, issue.IID), seed.Bytes())
+	if err != nil {
+		return path, "", err
+	}
+	body = strings.TrimSpace(string(stripComments(content, commentChar)))
+	if body == "" {
+		os.Remove(path)
+		return "", "", fmt.Errorf("empty comment")
+	}
+	return path, body, nil
+}
+
+// commentOnIssue is the "comment" command
+func commentOnIssue(ctx context.Context, client gitlabClient, repo *git.Repository, project *gitlab.Project, args []string) error {
+	flags := flag.NewFlagSet("comment", flag.ExitOnError)
+	message := flags.String("m", "", "comment body, skips the editor when set")
+	flags.Parse(args)
+	issue, err := client.getIssueFromArgs(ctx, project, flags.Args())
+	if err != nil {
+		return err
+	}
+	body := strings.TrimSpace(*message)
+	path := ""
+	if body == "" {
+		path, body, err = editComment(repo, project, issue)
+		if err != nil {
+			return fmt.Errorf("could not get comment: %w", err)
+		}
+	}
+	_, err = client.createIssueNote(ctx, project, issue.IID, body)
+	if err != nil {
+		if path != "" {
+			return fmt.Errorf("%w (draft saved to %s)", err, path)
+		}
+		return err
+	}
+	if path != "" {
+		os.Remove(path) // remove file once sure of success
+	}
+	if client.dryRun {
+		fmt.Println(body)
+		return nil
+	}
+	log.Printf("commented on: %s", issue.WebURL)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// isBoolFlag reports whether f takes no value, like -dry-run
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// printCompletion writes the completion script for shell, one of bash, zsh
+// or fish, completing commands and the flags in flags.
+func printCompletion(w io.Writer, shell string, commands []string, flags *flag.FlagSet) error {
+	switch shell {
+	case "bash":
+		names := []string{}
+		flags.VisitAll(func(f *flag.Flag) {
+			names = append(names, "-"+f.Name)
+		})
+		fmt.Fprintf(w, `_gitlab_completion() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	case $cur in
+	-*) COMPREPLY=($(compgen -W "%s" -- "$cur")) ;;
+	*) COMPREPLY=($(compgen -W "%s" -- "$cur")) ;;
+	esac
+}
+complete -o default -F _gitlab_completion gitlab
+`, strings.Join(names, " "), strings.Join(commands, " "))
+	case "zsh":
+		escape := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+		fmt.Fprintf(w, "#compdef gitlab\n\n_arguments \\\n")
+		flags.VisitAll(func(f *flag.Flag) {
+			spec := fmt.Sprintf("-%s[%s]", f.Name, escape.Replace(f.Usage))
+			if !isBoolFlag(f) {
+				spec += ":" + f.Name + ":"
+			}
+			fmt.Fprintf(w, "\t'%s' \\\n", spec)
+		})
+		fmt.Fprintf(w, "\t'1:command:(%s)' \\\n", strings.Join(commands, " "))
+		fmt.Fprintf(w, "\t'*:file:_files'\n")
+	case "fish":
+		escape := strings.NewReplacer("'", `\'`)
+		fmt.Fprintf(w, "complete -c gitlab -f -n __fish_use_subcommand -a '%s'\n", strings.Join(commands, " "))
+		flags.VisitAll(func(f *flag.Flag) {
+			requires := ""
+			if !isBoolFlag(f) {
+				requires = " -r"
+			}
+			fmt.Fprintf(w, "complete -c gitlab -o %s -d '%s'%s\n", f.Name, escape.Replace(f.Usage), requires)
+		})
+	default:
+		return fmt.Errorf("unknown shell %q, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
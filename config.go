@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"gopkg.in/yaml.v2"
+)
+
+// appConfig is read from ~/.config/gitlab/config.yml, or under
+// XDG_CONFIG_HOME when it is set
+type appConfig struct {
+	// Hosts maps a gitlab hostname to the token used for it
+	Hosts map[string]string `yaml:"hosts"`
+	// Open opens created issues in the browser, as the -open flag
+	Open bool `yaml:"open"`
+	// Epics selects an epic for created issues, as the -epic flag
+	Epics bool `yaml:"epics"`
+	// AskDueDate prompts for a due date when -due is not given
+	AskDueDate bool `yaml:"ask_due_date"`
+	// AskConfidential asks whether to make the issue confidential when
+	// -confidential is not given
+	AskConfidential bool `yaml:"ask_confidential"`
+	// AskWeight prompts for a weight when -weight is not given
+	AskWeight bool `yaml:"ask_weight"`
+	// AskLink asks whether to link the created issue to another issue when
+	// -relates-to is not given
+	AskLink bool `yaml:"ask_link"`
+	// DefaultTemplate is the issue template used without asking, as the
+	// -template flag
+	DefaultTemplate string `yaml:"default_template"`
+	// TemplateDir holds the local issue_templates and
+	// merge_request_templates instead of ~/.config/gitlab, overridden by
+	// GITLAB_TEMPLATE_DIR
+	TemplateDir string `yaml:"template_dir"`
+	// TemplateExtensions are the file extensions of templates, by default
+	// .md, .markdown and .txt
+	TemplateExtensions []string `yaml:"template_extensions"`
+	// TemplateProject is the path of a project whose templates are offered
+	// along with those of every project
+	TemplateProject string `yaml:"template_project"`
+	// Footer is added to the end of every issue written in the editor, with
+	// the same placeholders as templates, and removed if left unchanged
+	Footer string `yaml:"footer"`
+}
+
+// xdgConfigDir is $XDG_CONFIG_HOME, or ~/.config when it is not set
+func xdgConfigDir() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir != "" {
+		return dir, nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("could not get home-dir: %w", err)
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+func loadConfig() (appConfig, error) {
+	cfg := appConfig{}
+	configDir, err := xdgConfigDir()
+	if err != nil {
+		return cfg, err
+	}
+	configFile := filepath.Join(configDir, "gitlab", "config.yml")
+	b, err := ioutil.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("could not read config file %q: %w", configFile, err)
+	}
+	err = yaml.Unmarshal(b, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("could not parse config file %q: %w", configFile, err)
+	}
+	return cfg, nil
+}
+
+// errNoToken is returned when no token is found for the gitlab host
+var errNoToken = errors.New("no gitlab token, set GITLAB_TOKEN or see -help")
+
+// getToken resolves the token for host, in order of precedence from:
+// the -token flag, the file named by GITLAB_TOKEN_FILE, the hosts section of
+// the config file and finally GITLAB_TOKEN. A host with a port is looked up
+// in the hosts section with its port, then without.
+func getToken(flagToken string, cfg appConfig, host string) (string, error) {
+	if flagToken != "" {
+		return flagToken, nil
+	}
+	tokenFile := os.Getenv("GITLAB_TOKEN_FILE")
+	if tokenFile != "" {
+		b, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("could not read GITLAB_TOKEN_FILE %q: %w", tokenFile, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	for _, key := range []string{host, (&url.URL{Host: host}).Hostname()} {
+		if token, ok := cfg.Hosts[key]; ok {
+			return token, nil
+		}
+	}
+	return os.Getenv("GITLAB_TOKEN"), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXDGConfigDir(t *testing.T) {
+	home := isolateHome(t)
+	dir, err := xdgConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".config"); dir != want {
+		t.Errorf("without XDG_CONFIG_HOME got %s, want %s", dir, want)
+	}
+
+	xdg := filepath.Join(home, "xdg")
+	os.Setenv("XDG_CONFIG_HOME", xdg)
+	dir, err = xdgConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != xdg {
+		t.Errorf("with XDG_CONFIG_HOME got %s, want %s", dir, xdg)
+	}
+	if err := os.MkdirAll(filepath.Join(xdg, "gitlab"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(xdg, "gitlab", "config.yml"), []byte("open: true\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Open {
+		t.Errorf("config was not read from XDG_CONFIG_HOME")
+	}
+}
+
+func TestGetToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := appConfig{Hosts: map[string]string{
+		"gitlab.example.com": "from-hosts",
+		"gitlab.local:8080":  "from-ported-host",
+		"gitlab.local":       "from-bare-host",
+	}}
+	tests := []struct {
+		name      string
+		flag      string
+		tokenFile string
+		env       string
+		host      string
+		want      string
+	}{
+		{name: "flag first", flag: "from-flag", tokenFile: tokenFile, env: "from-env", host: "gitlab.example.com", want: "from-flag"},
+		{name: "then GITLAB_TOKEN_FILE", tokenFile: tokenFile, env: "from-env", host: "gitlab.example.com", want: "from-file"},
+		{name: "then hosts", env: "from-env", host: "gitlab.example.com", want: "from-hosts"},
+		{name: "host with its port", env: "from-env", host: "gitlab.local:8080", want: "from-ported-host"},
+		{name: "host without its port", env: "from-env", host: "gitlab.local:9090", want: "from-bare-host"},
+		{name: "then GITLAB_TOKEN", env: "from-env", host: "gitlab.com", want: "from-env"},
+		{name: "nothing set", host: "gitlab.com", want: ""},
+	}
+	for _, tt := range tests {
+		setenv(t, "GITLAB_TOKEN_FILE", tt.tokenFile)
+		setenv(t, "GITLAB_TOKEN", tt.env)
+		got, err := getToken(tt.flag, cfg, tt.host)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got token %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	setenv(t, "GITLAB_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := getToken("", cfg, "gitlab.com"); err == nil {
+		t.Error("a missing GITLAB_TOKEN_FILE was ignored")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// maxDiffSize is how much of the diff is posted, well below gitlab's limit on
+// the size of a comment
+const maxDiffSize = 64 * 1024
+
+// worktreeDiff is `git diff HEAD` in repo, the staged and unstaged changes to
+// tracked files, cut to maxDiffSize with a warning when it is larger.
+func worktreeDiff(repo *git.Repository) (string, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("could not get worktree: %w", err)
+	}
+	cmd := exec.Command("git", "diff", "HEAD")
+	cmd.Dir = worktree.Filesystem.Root()
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("could not run git diff: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("could not run git diff: %w", err)
+	}
+	diff := string(out)
+	if len(diff) > maxDiffSize {
+		log.Printf("The diff is %d bytes, only attaching the first %d", len(diff), maxDiffSize)
+		cut := strings.LastIndexByte(diff[:maxDiffSize], '\n')
+		if cut < 0 {
+			cut = maxDiffSize
+		}
+		diff = diff[:cut+1] + "... (diff truncated)\n"
+	}
+	return diff, nil
+}
+
+// diffNote is the comment body showing diff as a fenced code block, fenced
+// with more backticks than any run in the diff.
+func diffNote(diff string) string {
+	return fmt.Sprintf("Local changes when the issue was created:\n\n%s\n", codeBlock(diff, "diff"))
+}
+
+// codeBlock fences text as code in lang, with a fence longer than any run of
+// backticks in text so it can not be closed early
+func codeBlock(text, lang string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + fence
+}
+
+func (c gitlabClient) attachDiff(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue, diff string) error {
+	if c.dryRun {
+		infof("Dry run, not attaching the %d byte diff", len(diff))
+		return nil
+	}
+	_, err := c.createIssueNote(ctx, project, issue.IID, diffNote(diff))
+	if err != nil {
+		return fmt.Errorf("could not attach the diff: %w", err)
+	}
+	return nil
+}
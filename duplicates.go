@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// maxDuplicates is how many of the open issues matching a new issue's title
+// are shown
+const maxDuplicates = 10
+
+// findDuplicates returns the open issues of the project best matching title
+func (c gitlabClient) findDuplicates(ctx context.Context, project *gitlab.Project, title string) ([]*gitlab.Issue, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var issues []*gitlab.Issue
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		issues, resp, err = c.gitlab.Issues.ListProjectIssues(project.ID, &gitlab.ListProjectIssuesOptions{
+			ListOptions: gitlab.ListOptions{PerPage: maxDuplicates},
+			State:       gitlab.String("opened"),
+			Search:      gitlab.String(title),
+			In:          gitlab.String("title"),
+		}, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not search for duplicates: %w", err)
+	}
+	return issues, nil
+}
+
+// confirmNotDuplicate lists any open issues like an issue about to be
+// created called title and asks whether to create it anyway. The issue is
+// created without asking when the search fails.
+func (c gitlabClient) confirmNotDuplicate(ctx context.Context, project *gitlab.Project, title string) bool {
+	issues, err := c.findDuplicates(ctx, project, title)
+	if err != nil {
+		log.Printf("Not checking for duplicates: %s", describeErr(err))
+		return true
+	}
+	if len(issues) == 0 {
+		return true
+	}
+	fmt.Fprintf(os.Stderr, "Open issues like %q:\n", title)
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "  #%d %s %s\n", issue.IID, issue.Title, issue.WebURL)
+	}
+	return confirm("Create the issue anyway?", true)
+}
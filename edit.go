@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// updateIssue replaces the title and description of an issue
+func (c gitlabClient) updateIssue(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue, title, description string) (*gitlab.Issue, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		edited := *issue
+		edited.Title = title
+		edited.Description = description
+		return &edited, nil
+	}
+	var updated *gitlab.Issue
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		updated, resp, err = c.gitlab.Issues.UpdateIssue(project.ID, issue.IID, &gitlab.UpdateIssueOptions{
+			Title:       gitlab.String(title),
+			Description: gitlab.String(description),
+		}, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not update issue #%d: %w", issue.IID, err)
+	}
+	return updated, nil
+}
+
+// editIssueContent opens the title and description of issue in the editor,
+// returning the path of the file they were edited in, like seedTemplate does
+// for a new issue.
+func editIssueContent(repository *git.Repository, project *gitlab.Project, issue *gitlab.Issue) (path, title, description string, err error) {
+	commentChar := pickCommentChar([]byte(issue.Title + "\n" + issue.Description))
+	seed := bytes.Buffer{}
+	fmt.Fprintf(&seed, "%s\n", issue.Title)
+	fmt.Fprintf(&seed, "%c %s\n", commentChar, titleHelp)
+	fmt.Fprintf(&seed, "%c "+commentHelp+"\n", commentChar, commentChar)
+	seed.WriteByte('\n')
+	seed.WriteString(issue.Description)
+	path, content, err := editContent(repository, fmt.Sprintf("*_%s_%d_edit.md", project.Name, issue.IID), seed.Bytes())
+	if err != nil {
+		return path, "", "", err
+	}
+	title, description, err = splitTitle(stripComments(content, commentChar))
+	if err != nil {
+		return path, "", "", err
+	}
+	// the blank line separating the help from the description
+	description = strings.TrimPrefix(description, "\n")
+	if title == issue.Title && description == issue.Description {
+		os.Remove(path)
+		return "", "", "", fmt.Errorf("content has not been changed")
+	}
+	return path, title, description, nil
+}
+
+// editIssue is the "edit" command
+func editIssue(ctx context.Context, client gitlabClient, repo *git.Repository, project *gitlab.Project, args []string) error {
+	issue, err := client.getIssueFromArgs(ctx, project, args)
+	if err != nil {
+		return err
+	}
+	path, title, description, err := editIssueContent(repo, project, issue)
+	if err != nil {
+		if path != "" {
+			return fmt.Errorf("could not edit issue: %w (draft saved to %s)", err, path)
+		}
+		return fmt.Errorf("could not edit issue: %w", err)
+	}
+	issue, err = client.updateIssue(ctx, project, issue, title, description)
+	if err != nil {
+		return fmt.Errorf("%w (draft saved to %s)", err, path)
+	}
+	os.Remove(path) // remove file once sure of success
+	if client.dryRun {
+		printIssue(os.Stdout, issue, false)
+		return nil
+	}
+	log.Printf("edited: %s", issue.WebURL)
+	return nil
+}
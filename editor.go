@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitCommand splits an editor command like `code --wait` or
+// `"/opt/My Editor/edit" -w` into its arguments following the POSIX shell's
+// quoting rules: single quotes keep everything literally, double quotes allow
+// \ to escape $ ` " \ and newline, and outside quotes \ escapes any
+// character.
+func splitCommand(command string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				arg.WriteRune('\\')
+			}
+			if r != '\n' {
+				arg.WriteRune(r)
+				inArg = true
+			}
+		case quote == '\'':
+			if r == quote {
+				quote = 0
+				continue
+			}
+			arg.WriteRune(r)
+		case r == '\\':
+			escaped = true
+		case quote == '"':
+			if r == quote {
+				quote = 0
+				continue
+			}
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing \\ in %q", command)
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c in %q", quote, command)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty editor command")
+	}
+	return args, nil
+}
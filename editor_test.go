@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{command: "vim", want: []string{"vim"}},
+		{command: "code --wait", want: []string{"code", "--wait"}},
+		{command: "emacsclient -c", want: []string{"emacsclient", "-c"}},
+		{command: "  nano\t-w  ", want: []string{"nano", "-w"}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.command)
+		if err != nil {
+			t.Errorf("splitCommand(%q): %s", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCommandQuoting(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{command: `"/opt/My Editor/edit" -w`, want: []string{"/opt/My Editor/edit", "-w"}},
+		{command: `'/opt/My Editor/edit' -w`, want: []string{"/opt/My Editor/edit", "-w"}},
+		{command: `/opt/My\ Editor/edit -w`, want: []string{"/opt/My Editor/edit", "-w"}},
+		{command: `subl -n "--command=a b"`, want: []string{"subl", "-n", "--command=a b"}},
+		{command: `vim -c 'set tw=72'`, want: []string{"vim", "-c", "set tw=72"}},
+		{command: `ed "a\"b" "c\d"`, want: []string{"ed", `a"b`, `c\d`}},
+		{command: `ed 'a\b'`, want: []string{"ed", `a\b`}},
+		{command: `ed ""`, want: []string{"ed", ""}},
+		{command: `ed pre"fix suf"fix`, want: []string{"ed", "prefix suffix"}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.command)
+		if err != nil {
+			t.Errorf("splitCommand(%q): %s", tt.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+	for _, command := range []string{`vim "unterminated`, `vim 'unterminated`, `vim \`} {
+		if _, err := splitCommand(command); err == nil {
+			t.Errorf("splitCommand(%q) did not fail", command)
+		}
+	}
+}
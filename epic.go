@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// errEpicsUnavailable is returned when the project has no group or the
+// gitlab instance or plan does not have epics
+var errEpicsUnavailable = errors.New("epics are not available for this project")
+
+type issueEpic struct {
+	IID     int
+	GroupID int
+	Title   string
+}
+
+func (e issueEpic) String() string {
+	return fmt.Sprintf("&%d %s", e.IID, e.Title)
+}
+
+// getGroupEpics lists the open epics of the project's group and its parent
+// groups.
+func (c gitlabClient) getGroupEpics(ctx context.Context, project *gitlab.Project) ([]issueEpic, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	epics := []issueEpic{}
+	if project.Namespace == nil || project.Namespace.Kind != "group" {
+		return epics, errEpicsUnavailable
+	}
+	options := &gitlab.ListGroupEpicsOptions{
+		State:                 gitlab.String("opened"),
+		IncludeAncestorGroups: gitlab.Bool(true),
+		ListOptions:           gitlab.ListOptions{PerPage: 100},
+	}
+	for {
+		var page []*gitlab.Epic
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			page, resp, err = c.gitlab.Epics.ListGroupEpics(project.Namespace.ID, options, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+			return epics, errEpicsUnavailable
+		}
+		if err != nil {
+			return epics, err
+		}
+		for _, epic := range page {
+			epics = append(epics, issueEpic{IID: epic.IID, GroupID: epic.GroupID, Title: epic.Title})
+		}
+		if resp.NextPage == 0 {
+			return epics, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+// assignEpic adds issue to epic
+func (c gitlabClient) assignEpic(ctx context.Context, issue *gitlab.Issue, epic issueEpic) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		issue.Epic = &gitlab.Epic{IID: epic.IID, GroupID: epic.GroupID, Title: epic.Title}
+		return nil
+	}
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		_, resp, err = c.gitlab.EpicIssues.AssignEpicIssue(epic.GroupID, epic.IID, issue.ID, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return fmt.Errorf("could not add issue to epic %s: %w", epic, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// exit codes, so scripts can tell failures apart
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitUsage           = 2
+	exitNoRepo          = 3
+	exitAuth            = 4
+	exitProjectNotFound = 5
+	exitTimeout         = 6
+	exitIssuesDisabled  = 7
+	exitAborted         = 130
+)
+
+// usageError is a mistake in the flags or arguments given
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e usageError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf formats a usageError
+func usageErrorf(format string, args ...interface{}) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// exitCode is the exit code for an error returned by run
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errAborted):
+		return exitAborted
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, errNoRepo):
+		return exitNoRepo
+	case errors.Is(err, errNoToken), errors.Is(err, errUnauthorized):
+		return exitAuth
+	case errors.Is(err, errProjectNotFound):
+		return exitProjectNotFound
+	case errors.Is(err, errIssuesDisabled):
+		return exitIssuesDisabled
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	gitlab "github.com/xanzy/go-gitlab"
+	"gopkg.in/yaml.v2"
+)
+
+// formExtensions are the file extensions of issue forms, which are offered
+// along with the issue templates
+var formExtensions = []string{".yml", ".yaml"}
+
+// isFormFile reports whether fileName is an issue form
+func isFormFile(fileName string) bool {
+	_, ok := templateName(fileName, formExtensions)
+	return ok
+}
+
+// issueForm is an issue template asking for each field in turn, rather than
+// opening the editor, in a subset of GitHub's issue form schema. Other keys,
+// such as a field's id, are ignored.
+//
+//	name: Bug report
+//	title: "[bug] "
+//	labels: [bug]
+//	body:
+//	  - type: markdown
+//	    attributes:
+//	      value: Thanks for taking the time to report a bug
+//	  - type: input
+//	    attributes:
+//	      label: Version
+//	    validations:
+//	      required: true
+//	  - type: textarea
+//	    attributes:
+//	      label: What happened?
+//	  - type: dropdown
+//	    attributes:
+//	      label: OS
+//	      options: [linux, macOS, windows]
+//	  - type: checkboxes
+//	    attributes:
+//	      label: Checks
+//	      options:
+//	        - label: I searched for similar issues
+//	          required: true
+type issueForm struct {
+	Name string `yaml:"name"`
+	// Title is put before the title given
+	Title  string      `yaml:"title"`
+	Labels []string    `yaml:"labels"`
+	Body   []formField `yaml:"body"`
+}
+
+// formField is one element of an issueForm's body
+type formField struct {
+	Type       string `yaml:"type"`
+	Attributes struct {
+		Label       string `yaml:"label"`
+		Description string `yaml:"description"`
+		Placeholder string `yaml:"placeholder"`
+		// Value is the markdown of a markdown field, or the default answer
+		Value string `yaml:"value"`
+		// Render is the language an answer to a textarea is shown as code in
+		Render   string       `yaml:"render"`
+		Multiple bool         `yaml:"multiple"`
+		Options  []formOption `yaml:"options"`
+	} `yaml:"attributes"`
+	Validations struct {
+		Required bool `yaml:"required"`
+	} `yaml:"validations"`
+}
+
+// formOption is an option of a dropdown or checkboxes field, written as just
+// its label for a dropdown
+type formOption struct {
+	Label    string `yaml:"label"`
+	Required bool   `yaml:"required"`
+}
+
+func (o *formOption) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&o.Label); err == nil {
+		return nil
+	}
+	type option formOption
+	return unmarshal((*option)(o))
+}
+
+// noResponse is the answer shown for an optional field left blank
+const noResponse = "_No response_"
+
+// parseIssueForm reads and checks an issue form
+func parseIssueForm(content []byte) (issueForm, error) {
+	form := issueForm{}
+	err := yaml.Unmarshal(content, &form)
+	if err != nil {
+		return form, fmt.Errorf("could not parse issue form: %w", err)
+	}
+	if len(form.Body) == 0 {
+		return form, fmt.Errorf("issue form has no body")
+	}
+	for i, field := range form.Body {
+		switch field.Type {
+		case "markdown":
+			if field.Attributes.Value == "" {
+				return form, fmt.Errorf("markdown field %d of the issue form has no value", i+1)
+			}
+			continue
+		case "input", "textarea":
+		case "dropdown", "checkboxes":
+			if len(field.Attributes.Options) == 0 {
+				return form, fmt.Errorf("%s field %d of the issue form has no options", field.Type, i+1)
+			}
+		default:
+			return form, fmt.Errorf("field %d of the issue form has unknown type %q, expected one of markdown, input, textarea, dropdown or checkboxes", i+1, field.Type)
+		}
+		if field.Attributes.Label == "" {
+			return form, fmt.Errorf("%s field %d of the issue form has no label", field.Type, i+1)
+		}
+	}
+	return form, nil
+}
+
+// fill asks for each field of the form on the terminal, returning the
+// description made from the answers, each under a heading of its label.
+func (f issueForm) fill() (string, error) {
+	buf := strings.Builder{}
+	for _, field := range f.Body {
+		if field.Type == "markdown" {
+			fmt.Fprintf(os.Stderr, "%s\n\n", strings.TrimSpace(field.Attributes.Value))
+			continue
+		}
+		if field.Attributes.Description != "" {
+			fmt.Fprintf(os.Stderr, "%s\n", field.Attributes.Description)
+		}
+		answer, err := field.ask()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&buf, "### %s\n\n%s\n\n", field.Attributes.Label, answer)
+	}
+	return strings.TrimRight(buf.String(), "\n") + "\n", nil
+}
+
+// question is what the field is asked with on the terminal
+func (f formField) question() string {
+	q := f.Attributes.Label
+	if f.Validations.Required {
+		q += " (required)"
+	}
+	if f.Attributes.Value != "" {
+		q += fmt.Sprintf(" [%s]", f.Attributes.Value)
+	} else if f.Attributes.Placeholder != "" {
+		q += fmt.Sprintf(" (eg. %s)", f.Attributes.Placeholder)
+	}
+	return q
+}
+
+// ask asks for the field until it is answered, when required, returning the
+// answer as markdown
+func (f formField) ask() (string, error) {
+	switch f.Type {
+	case "dropdown":
+		return f.askDropdown()
+	case "checkboxes":
+		return f.askCheckboxes()
+	}
+	for {
+		var answer string
+		if f.Type == "textarea" {
+			answer = promptLines(f.question() + " (end with a line of just .):")
+		} else {
+			answer = prompt(f.question() + ":")
+		}
+		if answer == "" {
+			answer = f.Attributes.Value
+		}
+		if answer == "" {
+			if f.Validations.Required {
+				continue
+			}
+			return noResponse, nil
+		}
+		if f.Attributes.Render != "" {
+			return codeBlock(answer, f.Attributes.Render), nil
+		}
+		return answer, nil
+	}
+}
+
+func (f formField) askDropdown() (string, error) {
+	options := f.Attributes.Options
+	label := func(i int) string {
+		return options[i].Label
+	}
+	for {
+		var idxs []int
+		var err error
+		fmt.Fprintf(os.Stderr, "%s\n", f.question())
+		if f.Attributes.Multiple {
+			idxs, err = fuzzyfinder.FindMulti(options, label)
+		} else {
+			var idx int
+			idx, err = fuzzyfinder.Find(options, label)
+			idxs = []int{idx}
+		}
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			if f.Validations.Required {
+				return "", errAborted
+			}
+			return noResponse, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to select %s: %w", f.Attributes.Label, err)
+		}
+		if len(idxs) == 0 && f.Validations.Required {
+			continue
+		}
+		selected := []string{}
+		for _, idx := range idxs {
+			selected = append(selected, options[idx].Label)
+		}
+		return strings.Join(selected, ", "), nil
+	}
+}
+
+func (f formField) askCheckboxes() (string, error) {
+	options := f.Attributes.Options
+	for {
+		fmt.Fprintf(os.Stderr, "%s\n", f.question())
+		idxs, err := fuzzyfinder.FindMulti(options, func(i int) string {
+			if options[i].Required {
+				return options[i].Label + " (required)"
+			}
+			return options[i].Label
+		})
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			idxs = nil
+		} else if err != nil {
+			return "", fmt.Errorf("failed to select %s: %w", f.Attributes.Label, err)
+		}
+		checked := map[int]bool{}
+		for _, idx := range idxs {
+			checked[idx] = true
+		}
+		missing := ""
+		lines := []string{}
+		for i, option := range options {
+			box := "[ ]"
+			if checked[i] {
+				box = "[x]"
+			} else if option.Required {
+				missing = option.Label
+			}
+			lines = append(lines, fmt.Sprintf("- %s %s", box, option.Label))
+		}
+		if missing != "" {
+			if errors.Is(err, fuzzyfinder.ErrAbort) {
+				return "", errAborted
+			}
+			fmt.Fprintf(os.Stderr, "%q must be checked\n", missing)
+			continue
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+// createIssueFromForm asks for the fields of the issue form in template and
+// creates the issue from the answers, titled title or else what is asked for.
+// The form's labels are added to those in options. The answers are used as
+// they are written, unlike the placeholders of a template.
+func (c gitlabClient) createIssueFromForm(ctx context.Context, project *gitlab.Project, template issueTemplate, title string, options gitlab.CreateIssueOptions, summary issueSummary) (*gitlab.Issue, error) {
+	form, err := parseIssueForm(template.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", template.Name, err)
+	}
+	for title == "" {
+		title = prompt("Title:")
+	}
+	title = form.Title + title
+	// checked before the form is filled in, so no answers are wasted
+	if c.dupCheck && !c.confirmNotDuplicate(ctx, project, title) {
+		return nil, fmt.Errorf("not creating a duplicate issue")
+	}
+	description, err := form.fill()
+	if err != nil {
+		return nil, err
+	}
+	if len(form.Labels) > 0 {
+		selected := []issueLabel{}
+		for _, name := range options.Labels {
+			selected = append(selected, issueLabel{Name: name})
+		}
+		options.Labels = gitlab.Labels(withLabelNames(form.Labels, selected))
+	}
+	return c.submitIssue(ctx, project, title, description, options, summary)
+}
+
+// promptLines asks for lines of input on the terminal until a line of just
+// ".", or the end of input
+func promptLines(question string) string {
+	fmt.Fprintf(os.Stderr, "%s\n", question)
+	lines := bytes.Buffer{}
+	for {
+		line, err := stdin.ReadString('\n')
+		if strings.TrimRight(line, "\r\n") == "." {
+			break
+		}
+		lines.WriteString(line)
+		if err != nil {
+			break
+		}
+	}
+	return strings.TrimSpace(lines.String())
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	"github.com/nsf/termbox-go"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func TestFormFieldAbort(t *testing.T) {
+	dropdown := func(required, multiple bool) formField {
+		f := formField{Type: "dropdown"}
+		f.Attributes.Label = "OS"
+		f.Attributes.Multiple = multiple
+		f.Attributes.Options = []formOption{{Label: "linux"}, {Label: "macOS"}}
+		f.Validations.Required = required
+		return f
+	}
+	checkboxes := func(required bool) formField {
+		f := formField{Type: "checkboxes"}
+		f.Attributes.Label = "Checks"
+		f.Attributes.Options = []formOption{{Label: "I searched for similar issues", Required: required}}
+		return f
+	}
+	tests := []struct {
+		name    string
+		field   formField
+		want    string
+		wantErr error
+	}{
+		{name: "optional dropdown", field: dropdown(false, false), want: noResponse},
+		{name: "optional multiple dropdown", field: dropdown(false, true), want: noResponse},
+		{name: "required dropdown", field: dropdown(true, false), wantErr: errAborted},
+		{name: "required multiple dropdown", field: dropdown(true, true), wantErr: errAborted},
+		{name: "optional checkboxes", field: checkboxes(false), want: "- [ ] I searched for similar issues"},
+		{name: "required checkboxes", field: checkboxes(true), wantErr: errAborted},
+	}
+	for _, tt := range tests {
+		abortFinder()
+		got, err := tt.field.ask()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseIssueForm(t *testing.T) {
+	form, err := parseIssueForm([]byte(`
+name: Bug report
+title: "[bug] "
+labels: [bug, needs triage]
+body:
+  - type: markdown
+    attributes:
+      value: Thanks for reporting a bug
+  - type: input
+    id: version
+    attributes:
+      label: Version
+      placeholder: "1.2.3"
+    validations:
+      required: true
+  - type: textarea
+    attributes:
+      label: Logs
+      render: shell
+  - type: dropdown
+    attributes:
+      label: OS
+      multiple: true
+      options: [linux, macOS]
+  - type: checkboxes
+    attributes:
+      label: Checks
+      options:
+        - label: I searched for similar issues
+          required: true
+        - label: I can reproduce it
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Name != "Bug report" || form.Title != "[bug] " || !reflect.DeepEqual(form.Labels, []string{"bug", "needs triage"}) {
+		t.Errorf("got form %q titled %q with labels %q", form.Name, form.Title, form.Labels)
+	}
+	types := []string{}
+	for _, f := range form.Body {
+		types = append(types, f.Type)
+	}
+	if want := []string{"markdown", "input", "textarea", "dropdown", "checkboxes"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("got fields %q, want %q", types, want)
+	}
+	version, logs, platform, checks := form.Body[1], form.Body[2], form.Body[3], form.Body[4]
+	if !version.Validations.Required || version.Attributes.Placeholder != "1.2.3" {
+		t.Errorf("version field is %+v", version)
+	}
+	if logs.Attributes.Render != "shell" {
+		t.Errorf("logs are rendered as %q, want shell", logs.Attributes.Render)
+	}
+	if !platform.Attributes.Multiple || !reflect.DeepEqual(platform.Attributes.Options, []formOption{{Label: "linux"}, {Label: "macOS"}}) {
+		t.Errorf("dropdown is %+v", platform.Attributes)
+	}
+	want := []formOption{{Label: "I searched for similar issues", Required: true}, {Label: "I can reproduce it"}}
+	if !reflect.DeepEqual(checks.Attributes.Options, want) {
+		t.Errorf("got checkboxes %+v, want %+v", checks.Attributes.Options, want)
+	}
+}
+
+func TestParseIssueFormErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "not yaml", content: "body: [", want: "could not parse issue form"},
+		{name: "no body", content: "name: Bug", want: "issue form has no body"},
+		{name: "unknown type", content: "body:\n  - type: radio\n    attributes:\n      label: OS", want: `unknown type "radio"`},
+		{name: "markdown without value", content: "body:\n  - type: markdown", want: "markdown field 1 of the issue form has no value"},
+		{name: "input without label", content: "body:\n  - type: input", want: "input field 1 of the issue form has no label"},
+		{name: "dropdown without options", content: "body:\n  - type: markdown\n    attributes:\n      value: hi\n  - type: dropdown\n    attributes:\n      label: OS", want: "dropdown field 2 of the issue form has no options"},
+	}
+	for _, tt := range tests {
+		_, err := parseIssueForm([]byte(tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestFormFieldAsk(t *testing.T) {
+	field := func(typ string, required bool, value, render string) formField {
+		f := formField{Type: typ}
+		f.Attributes.Label = "Field"
+		f.Attributes.Value = value
+		f.Attributes.Render = render
+		f.Validations.Required = required
+		return f
+	}
+	tests := []struct {
+		name    string
+		field   formField
+		answers string
+		want    string
+	}{
+		{name: "input", field: field("input", false, "", ""), answers: "1.2.3\n", want: "1.2.3"},
+		{name: "optional input left blank", field: field("input", false, "", ""), answers: "\n", want: noResponse},
+		{name: "required input asked again", field: field("input", true, "", ""), answers: "\n  \n1.2.3\n", want: "1.2.3"},
+		{name: "default value", field: field("input", true, "main", ""), answers: "\n", want: "main"},
+		{name: "textarea", field: field("textarea", false, "", ""), answers: "line one\nline two\n.\nnot read\n", want: "line one\nline two"},
+		{name: "textarea at end of input", field: field("textarea", false, "", ""), answers: "only line", want: "only line"},
+		{name: "rendered textarea", field: field("textarea", false, "", "shell"), answers: "make test\n.\n", want: "```shell\nmake test\n```"},
+	}
+	defer func(r *bufio.Reader) { stdin = r }(stdin)
+	for _, tt := range tests {
+		stdin = bufio.NewReader(strings.NewReader(tt.answers))
+		got, err := tt.field.ask()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormFieldSelect(t *testing.T) {
+	enter := termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEnter}
+	dropdown := formField{Type: "dropdown"}
+	dropdown.Attributes.Label = "OS"
+	dropdown.Attributes.Options = []formOption{{Label: "linux"}, {Label: "macOS"}}
+	checkboxes := formField{Type: "checkboxes"}
+	checkboxes.Attributes.Label = "Checks"
+	checkboxes.Attributes.Options = []formOption{{Label: "I searched for similar issues", Required: true}, {Label: "I can reproduce it"}}
+	tests := []struct {
+		name  string
+		field formField
+		want  string
+	}{
+		{name: "dropdown", field: dropdown, want: "linux"},
+		{name: "checkboxes", field: checkboxes, want: "- [x] I searched for similar issues\n- [ ] I can reproduce it"},
+	}
+	for _, tt := range tests {
+		term := fuzzyfinder.UseMockedTerminal()
+		term.SetSize(60, 10)
+		term.SetEvents(enter)
+		got, err := tt.field.ask()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIssueFormFill(t *testing.T) {
+	form, err := parseIssueForm([]byte(`
+body:
+  - type: markdown
+    attributes:
+      value: Not part of the issue
+  - type: input
+    attributes:
+      label: Version
+  - type: input
+    attributes:
+      label: Browser
+  - type: textarea
+    attributes:
+      label: What happened?
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(r *bufio.Reader) { stdin = r }(stdin)
+	stdin = bufio.NewReader(strings.NewReader("1.2.3\n\nIt crashed\non start\n.\n"))
+	got, err := form.fill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "### Version\n\n1.2.3\n\n### Browser\n\n" + noResponse + "\n\n### What happened?\n\nIt crashed\non start\n"
+	if got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestCreateIssueFromForm(t *testing.T) {
+	searched := ""
+	var created map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/1/issues", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			searched = r.URL.Query().Get("search")
+			writeJSON(t, w, "", []gitlab.Issue{})
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+			t.Error(err)
+		}
+		writeJSON(t, w, "", gitlab.Issue{ID: 100, IID: 1})
+	})
+	client := newTestClient(t, mux)
+	client.dupCheck = true
+	template := issueTemplate{Name: "Bug", Form: true, Content: []byte("title: \"[bug] \"\nlabels: [bug]\nbody:\n  - type: input\n    attributes:\n      label: Steps\n")}
+	defer func(r *bufio.Reader) { stdin = r }(stdin)
+	stdin = bufio.NewReader(strings.NewReader("run {{project}} on {{date}}\n"))
+	options := gitlab.CreateIssueOptions{Labels: gitlab.Labels{"p1"}}
+	_, err := client.createIssueFromForm(context.Background(), &gitlab.Project{ID: 1, Name: "project"}, template, "Crash", options, issueSummary{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if searched != "[bug] Crash" {
+		t.Errorf("searched for duplicates of %q, want [bug] Crash", searched)
+	}
+	if created["title"] != "[bug] Crash" {
+		t.Errorf("created issue titled %q", created["title"])
+	}
+	if want := "### Steps\n\nrun {{project}} on {{date}}\n"; created["description"] != want {
+		t.Errorf("created issue with description %q, want the answer as it was written %q", created["description"], want)
+	}
+	if created["labels"] != "bug,p1" {
+		t.Errorf("created issue with labels %v, want bug,p1", created["labels"])
+	}
+}
@@ -6,6 +6,9 @@ require (
 	github.com/go-git/go-git/v5 v5.2.0
 	github.com/ktr0731/go-fuzzyfinder v0.2.1
 	github.com/mitchellh/go-homedir v1.1.0
+	github.com/nsf/termbox-go v0.0.0-20200418040025-38ba6e5628f1
 	github.com/xanzy/go-gitlab v0.39.0
-	golang.org/x/sync v0.0.0-20190423024810-112230192c58 // indirect
+	golang.org/x/sync v0.0.0-20190423024810-112230192c58
+	golang.org/x/term v0.0.0-20201126162022-7de9c90e9dd1
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// linkTypes are the kinds of link from one issue to another
+var linkTypes = []string{"relates_to", "blocks", "is_blocked_by"}
+
+func validLinkType(linkType string) bool {
+	for _, t := range linkTypes {
+		if t == linkType {
+			return true
+		}
+	}
+	return false
+}
+
+// createIssueLinkOptions adds the link type missing from
+// gitlab.CreateIssueLinkOptions
+type createIssueLinkOptions struct {
+	TargetProjectID *string `json:"target_project_id"`
+	TargetIssueIID  *string `json:"target_issue_iid"`
+	LinkType        *string `json:"link_type,omitempty"`
+}
+
+// linkIssue links issue to the issue targetIID of the same project, with
+// linkType one of linkTypes.
+func (c gitlabClient) linkIssue(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue, targetIID int, linkType string) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		infof("Dry run, not linking to #%d (%s)", targetIID, linkType)
+		return nil
+	}
+	options := &createIssueLinkOptions{
+		TargetProjectID: gitlab.String(strconv.Itoa(project.ID)),
+		TargetIssueIID:  gitlab.String(strconv.Itoa(targetIID)),
+		LinkType:        gitlab.String(linkType),
+	}
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		req, err := c.gitlab.NewRequest(http.MethodPost, fmt.Sprintf("projects/%d/issues/%d/links", project.ID, issue.IID), options, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+		if err != nil {
+			return nil, err
+		}
+		return c.gitlab.Do(req, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("could not link issue to #%d: %w", targetIID, err)
+	}
+	return nil
+}
+
+// selectRelatedIssue lets the user pick an open issue, other than issue, to
+// link issue to.
+func (c gitlabClient) selectRelatedIssue(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue) (int, error) {
+	issues, err := c.getIssues(ctx, project, &gitlab.ListProjectIssuesOptions{State: gitlab.String("opened")})
+	if err != nil {
+		return 0, fmt.Errorf("could not list issues: %w", err)
+	}
+	others := []*gitlab.Issue{}
+	for _, i := range issues {
+		if i.IID != issue.IID {
+			others = append(others, i)
+		}
+	}
+	related, err := selectIssue(others)
+	if err != nil {
+		return 0, err
+	}
+	return related.IID, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// listIssues requests a page of the project's issues, or with a nil project
+// of the issues in every project the user can see
+func (c gitlabClient) listIssues(ctx context.Context, project *gitlab.Project, options *gitlab.ListProjectIssuesOptions) ([]*gitlab.Issue, *gitlab.Response, error) {
+	if project != nil {
+		return c.gitlab.Issues.ListProjectIssues(project.ID, options, gitlab.WithContext(ctx))
+	}
+	return c.gitlab.Issues.ListIssues(&gitlab.ListIssuesOptions{
+		ListOptions:      options.ListOptions,
+		State:            options.State,
+		Labels:           options.Labels,
+		Milestone:        options.Milestone,
+		Scope:            gitlab.String("all"),
+		AuthorID:         options.AuthorID,
+		AssigneeID:       options.AssigneeID,
+		AssigneeUsername: options.AssigneeUsername,
+	}, gitlab.WithContext(ctx))
+}
+
+func (c gitlabClient) getIssues(ctx context.Context, project *gitlab.Project, options *gitlab.ListProjectIssuesOptions) ([]*gitlab.Issue, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	issues := []*gitlab.Issue{}
+	options.PerPage = 100
+	for {
+		var page []*gitlab.Issue
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			page, resp, err = c.listIssues(ctx, project, options)
+			return resp, err
+		})
+		if err != nil {
+			return issues, err
+		}
+		issues = append(issues, page...)
+		if resp.NextPage == 0 {
+			return issues, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+// selectIssue lets the user pick one of issues with the fuzzyfinder
+func selectIssue(issues []*gitlab.Issue) (*gitlab.Issue, error) {
+	return selectIssueFrom(issues, false)
+}
+
+// selectIssueFrom is selectIssue, naming each issue's project when they are
+// from more than one
+func selectIssueFrom(issues []*gitlab.Issue, allProjects bool) (*gitlab.Issue, error) {
+	if len(issues) == 0 {
+		return nil, fmt.Errorf("no issues found")
+	}
+	idx, err := fuzzyfinder.Find(
+		issues,
+		func(i int) string {
+			ref := fmt.Sprintf("#%d", issues[i].IID)
+			if allProjects && issues[i].References != nil {
+				ref = issues[i].References.Full
+			}
+			s := ref + " " + issues[i].Title
+			if issues[i].State == "closed" {
+				s += " (closed)"
+			}
+			if len(issues[i].Labels) > 0 {
+				s += " [" + strings.Join(issues[i].Labels, ", ") + "]"
+			}
+			return s
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select issue: %w", err)
+	}
+	return issues[idx], nil
+}
+
+// maxListLimit is the most issues gitlab returns in one page
+const maxListLimit = 100
+
+// getIssuePage returns the page of issues selected by options, along with
+// the number of the next page, which is 0 on the last page
+func (c gitlabClient) getIssuePage(ctx context.Context, project *gitlab.Project, options *gitlab.ListProjectIssuesOptions) ([]*gitlab.Issue, int, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var issues []*gitlab.Issue
+	resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		issues, resp, err = c.listIssues(ctx, project, options)
+		return resp, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return issues, resp.NextPage, nil
+}
+
+// findUser looks up the user with username
+func (c gitlabClient) findUser(ctx context.Context, username string) (*gitlab.User, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var users []*gitlab.User
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		users, resp, err = c.gitlab.Users.ListUsers(&gitlab.ListUsersOptions{Username: gitlab.String(username)}, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not find user %q: %w", username, err)
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("no user with username %q", username)
+	}
+	return users[0], nil
+}
+
+// listOpenIssues is the "list" command, printing the URL of the selected
+// open issue. Only a page of -limit issues is fetched, the most recently
+// created first, so large projects stay quick to list. user is nil when the
+// current user is not known.
+func listOpenIssues(ctx context.Context, client gitlabClient, project *gitlab.Project, user *gitlab.User, open bool, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	limit := flags.Int("limit", 50, fmt.Sprintf("list at most this many issues, up to %d, or 0 for all of them", maxListLimit))
+	page := flags.Int("page", 1, "list this page of -limit issues")
+	var labels stringsFlag
+	flags.Var(&labels, "label", "only issues with this label, may be repeated")
+	milestone := flags.String("milestone", "", "only issues in the milestone with this title")
+	assignee := flags.String("assignee", "", "only issues assigned to this username")
+	author := flags.String("author", "", "only issues created by this username")
+	mine := flags.Bool("mine", false, "only issues assigned to you")
+	allProjects := flags.Bool("all-projects", false, "list issues from every project you can see rather than just this one")
+	flags.Parse(args)
+	if *mine && *assignee != "" {
+		return usageErrorf("-mine and -assignee can not be used together")
+	}
+	if *mine && user == nil {
+		return usageErrorf("-mine needs a token for a user, CI_JOB_TOKEN can not look up the current user")
+	}
+	if *limit < 0 || *limit > maxListLimit {
+		return usageErrorf("-limit must be from 0 to %d", maxListLimit)
+	}
+	if *page < 1 {
+		return usageErrorf("-page must be 1 or more")
+	}
+	if *limit == 0 && *page != 1 {
+		return usageErrorf("-page needs a -limit")
+	}
+	options := &gitlab.ListProjectIssuesOptions{State: gitlab.String("opened")}
+	if len(labels) > 0 {
+		options.Labels = gitlab.Labels(labels)
+	}
+	if *milestone != "" {
+		options.Milestone = milestone
+	}
+	if *assignee != "" {
+		options.AssigneeUsername = gitlab.String(strings.TrimPrefix(*assignee, "@"))
+	}
+	if *mine {
+		options.AssigneeID = gitlab.Int(user.ID)
+	}
+	if *allProjects {
+		project = nil
+	}
+	if *author != "" {
+		// the API in this client only filters by author ID
+		user, err := client.findUser(ctx, strings.TrimPrefix(*author, "@"))
+		if err != nil {
+			return err
+		}
+		options.AuthorID = gitlab.Int(user.ID)
+	}
+	var issues []*gitlab.Issue
+	var err error
+	if *limit == 0 {
+		issues, err = client.getIssues(ctx, project, options)
+	} else {
+		var next int
+		options.PerPage = *limit
+		options.Page = *page
+		issues, next, err = client.getIssuePage(ctx, project, options)
+		if err == nil && next != 0 {
+			infof("Listing %d issues, see more with -page %d", len(issues), next)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not list issues: %w", err)
+	}
+	if len(issues) == 0 && *mine {
+		return fmt.Errorf("no open issues are assigned to you")
+	}
+	issue, err := selectIssueFrom(issues, *allProjects)
+	if err != nil {
+		return err
+	}
+	fmt.Println(issue.WebURL)
+	shareURL(issue.WebURL, open, false)
+	return nil
+}
@@ -2,176 +2,757 @@ package main
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/ktr0731/go-fuzzyfinder"
-	"github.com/mitchellh/go-homedir"
 	gitlab "github.com/xanzy/go-gitlab"
 )
 
+// errNoRepo is returned by findRepo when path is not in a git repository
+var errNoRepo = errors.New("not in a git repository")
+
+// findRepo opens the git repository containing path
 func findRepo(path string) (*git.Repository, error) {
-	repo, err := git.PlainOpen(path)
-	if err != nil && path != "/" {
-		repo, err = findRepo(filepath.Dir(path))
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		return nil, errNoRepo
 	}
 	if err != nil {
-		return nil, fmt.Errorf("no git repository in %q: %w", path, err)
+		return nil, fmt.Errorf("could not open git repository in %q: %w", path, err)
 	}
 	return repo, nil
 }
 
-type gitlabClient struct {
-	gitlab *gitlab.Client
+// defaultHost is the gitlab instance used when the project is not found from
+// a git remote
+const defaultHost = "gitlab.com"
+
+// pickGitlabURL picks the URL of a remote with several that is on gitlab:
+// the first on instanceHost when it is set, else the first whose host looks
+// like a gitlab instance, else the first.
+func pickGitlabURL(urls []string, instanceHost string) string {
+	if len(urls) == 0 {
+		return ""
+	}
+	hostname := func(host string) string {
+		return (&url.URL{Host: host}).Hostname()
+	}
+	if instanceHost != "" {
+		for _, u := range urls {
+			host, _, err := parseRemoteURL(u)
+			if err == nil && strings.EqualFold(hostname(host), hostname(instanceHost)) {
+				return u
+			}
+		}
+	}
+	for _, u := range urls {
+		host, _, err := parseRemoteURL(u)
+		if err == nil && strings.Contains(strings.ToLower(host), "gitlab") {
+			return u
+		}
+	}
+	return urls[0]
+}
+
+// remoteProject finds the gitlab host, the scheme its API is served with,
+// and the project path from a git remote, picking its URL on instanceHost,
+// if set, when it has several
+func remoteProject(repo *git.Repository, remoteName, instanceHost string) (scheme, host, projectPath string, err error) {
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		remotes, _ := repo.Remotes()
+		names := []string{}
+		for _, r := range remotes {
+			names = append(names, r.Config().Name)
+		}
+		return "", "", "", fmt.Errorf("error getting remote %s: %w (available remotes: %s)", remoteName, err, strings.Join(names, ", "))
+	}
+	remoteURL := pickGitlabURL(remote.Config().URLs, instanceHost)
+	if remoteURL == "" {
+		return "", "", "", fmt.Errorf("remote %s has no URL", remoteName)
+	}
+	infof("Remote URL: %s", redactURL(remoteURL))
+	host, projectPath, err = parseRemoteURL(remoteURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error parsing URL for remote %s: %w", remoteName, err)
+	}
+	return remoteScheme(remoteURL), host, projectPath, nil
+}
+
+// errUnauthorized is returned when gitlab rejects the token
+var errUnauthorized = errors.New("invalid or missing GITLAB_TOKEN")
+
+func (c gitlabClient) currentUser(ctx context.Context) (*gitlab.User, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	user, resp, err := c.gitlab.Users.CurrentUser(gitlab.WithContext(ctx))
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+		return nil, errUnauthorized
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get current user: %w", err)
+	}
+	return user, nil
+}
+
+// describeErr explains an error from the gitlab API, calling out timeouts
+// rather than showing the raw context error. The messages wrapping a timed
+// out request are kept, so it still says what timed out.
+func describeErr(err error) string {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err.Error()
+	}
+	inner := errors.Unwrap(err)
+	if _, ok := err.(*url.Error); ok || inner == nil || !strings.Contains(err.Error(), inner.Error()) {
+		return "timed out waiting for gitlab, try increasing -timeout"
+	}
+	return strings.Replace(err.Error(), inner.Error(), describeErr(inner), 1)
+}
+
+// parseRemoteURL splits a git remote URL into its host and namespaced project
+// path. It understands https://, ssh:// and scp-like (git@host:group/project.git)
+// remotes.
+func parseRemoteURL(raw string) (host, projectPath string, err error) {
+	if !strings.Contains(raw, "://") {
+		// scp-like syntax: [user@]host:path
+		colon := strings.Index(raw, ":")
+		if colon == -1 {
+			return "", "", fmt.Errorf("unrecognised remote URL %q", raw)
+		}
+		host = raw[:colon]
+		if at := strings.LastIndex(host, "@"); at != -1 {
+			host = host[at+1:]
+		}
+		projectPath = raw[colon+1:]
+	} else {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("could not parse remote URL %q", redactURL(raw))
+		}
+		host = u.Hostname()
+		if u.Scheme == "http" || u.Scheme == "https" {
+			// keep a non-standard port as the API is served on it too,
+			// unlike the port of an ssh:// remote
+			host = u.Host
+		}
+		projectPath = u.Path
+	}
+	projectPath = strings.Trim(strings.TrimSuffix(projectPath, ".git"), "/")
+	if host == "" || projectPath == "" {
+		return "", "", fmt.Errorf("could not find host and project path in remote URL %q", redactURL(raw))
+	}
+	return host, projectPath, nil
+}
+
+// remoteScheme is the scheme the API is served with for a remote URL: http
+// for an http:// remote, as its port is kept too, otherwise https
+func remoteScheme(raw string) string {
+	if strings.HasPrefix(strings.ToLower(raw), "http://") {
+		return "http"
+	}
+	return "https"
+}
+
+// apiURL returns the API root for a gitlab instance and the project path
+// relative to it. instanceURL overrides the instance derived from the remote's
+// scheme and host, and may include a path prefix the instance is served under
+// which is then removed from projectPath.
+func apiURL(instanceURL, scheme, host, projectPath string) (*url.URL, string, error) {
+	if instanceURL == "" {
+		return &url.URL{Scheme: scheme, Host: host, Path: "/api/v4"}, projectPath, nil
+	}
+	u, err := url.Parse(instanceURL)
+	if err != nil || u.Host == "" {
+		return nil, "", fmt.Errorf("invalid gitlab base URL %q", instanceURL)
+	}
+	prefix := strings.Trim(strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v4"), "/")
+	u.Path = "/api/v4"
+	if prefix != "" {
+		u.Path = "/" + prefix + u.Path
+		projectPath = strings.TrimPrefix(projectPath, prefix+"/")
+	}
+	return u, projectPath, nil
+}
+
+// redactURL removes any credentials from a remote URL so it can be logged
+func redactURL(raw string) string {
+	schemeEnd := strings.Index(raw, "://")
+	if schemeEnd == -1 {
+		return raw
+	}
+	rest := raw[schemeEnd+len("://"):]
+	hostEnd := strings.Index(rest, "/")
+	if hostEnd == -1 {
+		hostEnd = len(rest)
+	}
+	if at := strings.LastIndex(rest[:hostEnd], "@"); at != -1 {
+		return raw[:schemeEnd+len("://")] + rest[at+1:]
+	}
+	return raw
+}
+
+func (c gitlabClient) getProjectFromOrigin(ctx context.Context, projectPath string) (*gitlab.Project, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	// go-gitlab path-escapes string project IDs itself
+	project, resp, err := c.gitlab.Projects.GetProject(projectPath, nil, gitlab.WithContext(ctx))
+	if err == nil {
+		return project, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("failed to get project %q: %w", projectPath, err)
+	}
+	return c.searchProject(ctx, projectPath)
+}
+
+// errProjectNotFound is returned when no project has the remote's path
+var errProjectNotFound = errors.New("could not find project")
+
+// errIssuesDisabled is returned when issues can not be created in a project
+var errIssuesDisabled = errors.New("issues are disabled")
+
+// issuesDisabled reports whether the project has issues turned off, as
+// mirrors and wiki only projects often do
+func issuesDisabled(project *gitlab.Project) bool {
+	if project.IssuesAccessLevel != "" {
+		return project.IssuesAccessLevel == gitlab.DisabledAccessControl
+	}
+	return !project.IssuesEnabled
 }
 
-func (c gitlabClient) getProjectFromOrigin(originURL *url.URL) (*gitlab.Project, error) {
-	projectPath := strings.TrimSuffix(originURL.Path, ".git")
+// searchProject is the fallback for getProjectFromOrigin when the project can
+// not be fetched directly by path, eg. when the remote path is a redirect.
+func (c gitlabClient) searchProject(ctx context.Context, projectPath string) (*gitlab.Project, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
 	projectName := filepath.Base(projectPath)
-	projects, _, err := c.gitlab.Projects.ListProjects(
-		&gitlab.ListProjectsOptions{Search: gitlab.String(projectName)},
-	)
+	var projects []*gitlab.Project
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		projects, resp, err = c.gitlab.Projects.ListProjects(
+			&gitlab.ListProjectsOptions{Search: gitlab.String(projectName)},
+			gitlab.WithContext(ctx),
+		)
+		return resp, err
+	})
 	if err != nil {
 		return &gitlab.Project{}, fmt.Errorf("failed to list projects: %w", err)
 	}
 	for _, project := range projects {
-		if "/"+project.PathWithNamespace == projectPath {
+		if project.PathWithNamespace == projectPath {
 			return project, nil
 		}
 	}
-	return nil, fmt.Errorf("could not find project")
+	return nil, errProjectNotFound
+}
+
+// getMemberProjects lists the projects the user is a member of, most
+// recently active first.
+func (c gitlabClient) getMemberProjects(ctx context.Context) ([]*gitlab.Project, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	projects := []*gitlab.Project{}
+	options := &gitlab.ListProjectsOptions{
+		Membership:  gitlab.Bool(true),
+		OrderBy:     gitlab.String("last_activity_at"),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	}
+	for {
+		var page []*gitlab.Project
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			page, resp, err = c.gitlab.Projects.ListProjects(options, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return projects, fmt.Errorf("failed to list projects: %w", err)
+		}
+		projects = append(projects, page...)
+		if resp.NextPage == 0 {
+			return projects, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+// selectProject lets the user pick one of the projects they are a member of,
+// for when the project is not found from the remote.
+func (c gitlabClient) selectProject(ctx context.Context) (*gitlab.Project, error) {
+	projects, err := c.getMemberProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, errProjectNotFound
+	}
+	idx, err := fuzzyfinder.Find(
+		projects,
+		func(i int) string {
+			return projects[i].PathWithNamespace
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select project: %w", err)
+	}
+	return projects[idx], nil
+}
+
+// findProject gets the project for the remote's path, letting the user
+// select it when it can not be found and offerProjects is set.
+func (c gitlabClient) findProject(ctx context.Context, projectPath string) (project *gitlab.Project, selected bool, err error) {
+	project, err = c.getProjectFromOrigin(ctx, projectPath)
+	if !errors.Is(err, errProjectNotFound) || !c.offerProjects {
+		return project, false, err
+	}
+	log.Printf("Could not find project %s, select it instead", projectPath)
+	project, err = c.selectProject(ctx)
+	return project, err == nil, err
 }
 
 type issueLabel struct {
 	ID          int
 	Name        string
 	Description string
+	// Color is a hex code like #FF0000
+	Color string
 }
 
-var noLabels = []issueLabel{{ID: 0, Name: "non-existant"}}
+// String is the label as shown when selecting labels
+func (l issueLabel) String() string {
+	s := l.Name
+	if l.Color != "" {
+		s += fmt.Sprintf(" (%s)", l.Color)
+	}
+	if l.Description != "" {
+		s += ": " + l.Description
+	}
+	return s
+}
 
-func (c gitlabClient) getIssueLabels(project *gitlab.Project) ([]issueLabel, error) {
-	l := []issueLabel{}
-	labels, _, err := c.gitlab.Labels.ListLabels(project.ID, &gitlab.ListLabelsOptions{})
+// newLabel is the label selection entry for creating a label
+var newLabel = issueLabel{Name: "＋ create new label…"}
+
+// recalledLabels is the label selection entry for the labels last used in
+// the project
+func recalledLabels(labels []issueLabel) issueLabel {
+	names := []string{}
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	return issueLabel{Name: "↺ last used: " + strings.Join(names, ", ")}
+}
+
+// findLabelsContaining returns the project's labels whose names contain any
+// of substrings, ignoring case. A substring matching no label is logged.
+func (c gitlabClient) findLabelsContaining(ctx context.Context, project *gitlab.Project, substrings []string) ([]issueLabel, error) {
+	if len(substrings) == 0 {
+		return nil, nil
+	}
+	labels, err := c.getIssueLabels(ctx, project)
 	if err != nil {
-		return l, err
+		return nil, fmt.Errorf("could not get labels: %w", err)
 	}
-	for _, label := range labels {
-		l = append(l, issueLabel{ID: label.ID, Name: label.Name, Description: label.Description})
+	matched := []issueLabel{}
+	for _, substring := range substrings {
+		found := false
+		for _, l := range labels {
+			if strings.Contains(strings.ToLower(l.Name), strings.ToLower(substring)) {
+				matched = withLabel(matched, l)
+				found = true
+			}
+		}
+		if !found {
+			log.Printf("No labels match -label-match %q", substring)
+		}
 	}
-	return l, nil
+	return matched, nil
 }
 
-type issueMilestone struct {
-	ID   int
-	Name string
+// matchLabels returns the labels with the given names, ignoring names which
+// are no longer labels.
+func matchLabels(labels []issueLabel, names []string) []issueLabel {
+	matched := []issueLabel{}
+	for _, name := range names {
+		for _, l := range labels {
+			if l.Name == name {
+				matched = append(matched, l)
+				break
+			}
+		}
+	}
+	return matched
 }
 
-var noMilestone = issueMilestone{ID: 0, Name: "non-existant"}
+// defaultLabelColor is used for a new label when no color is given
+const defaultLabelColor = "#428BCA"
 
-func (c gitlabClient) getIssueMilestones(project *gitlab.Project) ([]issueMilestone, error) {
-	m := []issueMilestone{}
-	milestones, _, err := c.gitlab.Milestones.ListMilestones(project.ID, &gitlab.ListMilestonesOptions{State: gitlab.String("active")})
+// createLabel creates a project label, given color as a hex code like
+// #FF0000 or a CSS color name.
+func (c gitlabClient) createLabel(ctx context.Context, project *gitlab.Project, name, color string) (issueLabel, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		return issueLabel{Name: name}, nil
+	}
+	var label *gitlab.Label
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		label, resp, err = c.gitlab.Labels.CreateLabel(project.ID, &gitlab.CreateLabelOptions{
+			Name:  gitlab.String(name),
+			Color: gitlab.String(color),
+		}, gitlab.WithContext(ctx))
+		return resp, err
+	})
 	if err != nil {
-		return m, err
+		return issueLabel{}, fmt.Errorf("could not create label %q: %w", name, err)
+	}
+	return issueLabel{ID: label.ID, Name: label.Name, Description: label.Description, Color: label.Color}, nil
+}
+
+// promptNewLabel asks for the name and color of a label and creates it
+func (c gitlabClient) promptNewLabel(ctx context.Context, project *gitlab.Project) (issueLabel, error) {
+	name := prompt("New label name:")
+	if name == "" {
+		return issueLabel{}, fmt.Errorf("empty label name")
+	}
+	color := prompt(fmt.Sprintf("Label color (blank for %s):", defaultLabelColor))
+	if color == "" {
+		color = defaultLabelColor
+	}
+	return c.createLabel(ctx, project, name, color)
+}
+
+func (c gitlabClient) getIssueLabels(ctx context.Context, project *gitlab.Project) ([]issueLabel, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	l := []issueLabel{}
+	options := &gitlab.ListLabelsOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		var labels []*gitlab.Label
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			labels, resp, err = c.gitlab.Labels.ListLabels(project.ID, options, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return l, err
+		}
+		for _, label := range labels {
+			l = append(l, issueLabel{ID: label.ID, Name: label.Name, Description: label.Description, Color: label.Color})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+	if project.Namespace == nil || project.Namespace.Kind != "group" {
+		return l, nil
+	}
+	seen := map[string]bool{}
+	for _, label := range l {
+		seen[label.Name] = true
 	}
-	for _, milestone := range milestones {
-		m = append(m, issueMilestone{ID: milestone.ID, Name: milestone.Title})
+	groupOptions := &gitlab.ListGroupLabelsOptions{PerPage: 100}
+	for {
+		var labels []*gitlab.GroupLabel
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			labels, resp, err = c.gitlab.GroupLabels.ListGroupLabels(project.Namespace.ID, groupOptions, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return l, err
+		}
+		for _, label := range labels {
+			if seen[label.Name] {
+				continue
+			}
+			seen[label.Name] = true
+			l = append(l, issueLabel{ID: label.ID, Name: label.Name, Description: label.Description, Color: label.Color})
+		}
+		if resp.NextPage == 0 {
+			return l, nil
+		}
+		groupOptions.Page = resp.NextPage
 	}
-	return m, nil
 }
 
-type issueTemplate struct {
+type issueMilestone struct {
+	ID      int
 	Name    string
-	Content []byte
+	DueDate *gitlab.ISOTime
+	// Group is set for milestones inherited from the project's group
+	Group bool
 }
 
-func getLocalIssueTemplates() ([]issueTemplate, error) {
-	issueTemplates := []issueTemplate{}
-	home, err := homedir.Dir()
-	if err != nil {
-		return issueTemplates, fmt.Errorf("could not get home-dir: %w", err)
+func (m issueMilestone) String() string {
+	s := m.Name
+	if m.DueDate != nil {
+		s += fmt.Sprintf(" (due %s)", m.DueDate)
+	}
+	if m.Group {
+		s += " [group]"
+	}
+	return s
+}
+
+// sortMilestones orders milestones by due date, nearest first, followed by
+// those without a due date.
+func sortMilestones(m []issueMilestone) {
+	sort.SliceStable(m, func(i, j int) bool {
+		if m[i].DueDate == nil || m[j].DueDate == nil {
+			return m[j].DueDate == nil && m[i].DueDate != nil
+		}
+		return time.Time(*m[i].DueDate).Before(time.Time(*m[j].DueDate))
+	})
+}
+
+// newMilestone is the milestone selection entry for creating a milestone
+var newMilestone = issueMilestone{Name: "＋ create new milestone…"}
+
+func (c gitlabClient) createMilestone(ctx context.Context, project *gitlab.Project, title string, dueDate *gitlab.ISOTime) (issueMilestone, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		return issueMilestone{Name: title, DueDate: dueDate}, nil
 	}
-	localTemplateDir := filepath.Join(home, ".config", "gitlab", "issue_templates")
-	err = os.MkdirAll(localTemplateDir, os.ModePerm)
+	var milestone *gitlab.Milestone
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		milestone, resp, err = c.gitlab.Milestones.CreateMilestone(project.ID, &gitlab.CreateMilestoneOptions{
+			Title:   gitlab.String(title),
+			DueDate: dueDate,
+		}, gitlab.WithContext(ctx))
+		return resp, err
+	})
 	if err != nil {
-		return issueTemplates, fmt.Errorf("couuld not make dir %q: %w", localTemplateDir, err)
+		return issueMilestone{}, fmt.Errorf("could not create milestone %q: %w", title, err)
 	}
-	files, err := ioutil.ReadDir(localTemplateDir)
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".md") {
-			continue
+	return issueMilestone{ID: milestone.ID, Name: milestone.Title, DueDate: milestone.DueDate}, nil
+}
+
+// promptNewMilestone asks for the title and due date of a milestone and
+// creates it
+func (c gitlabClient) promptNewMilestone(ctx context.Context, project *gitlab.Project) (issueMilestone, error) {
+	title := prompt("New milestone title:")
+	if title == "" {
+		return issueMilestone{}, fmt.Errorf("empty milestone title")
+	}
+	dueDate := promptDate("Milestone due date (YYYY-MM-DD, blank for none):")
+	return c.createMilestone(ctx, project, title, dueDate)
+}
+
+func (c gitlabClient) getIssueMilestones(ctx context.Context, project *gitlab.Project) ([]issueMilestone, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	m := []issueMilestone{}
+	options := &gitlab.ListMilestonesOptions{
+		State:       gitlab.String("active"),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	}
+	for {
+		var milestones []*gitlab.Milestone
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			milestones, resp, err = c.gitlab.Milestones.ListMilestones(project.ID, options, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return m, err
+		}
+		for _, milestone := range milestones {
+			m = append(m, issueMilestone{ID: milestone.ID, Name: milestone.Title, DueDate: milestone.DueDate})
 		}
-		b, err := ioutil.ReadFile(filepath.Join(localTemplateDir, file.Name()))
+		if resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+	if project.Namespace == nil || project.Namespace.Kind != "group" {
+		sortMilestones(m)
+		return m, nil
+	}
+	seen := map[string]bool{}
+	for _, milestone := range m {
+		seen[milestone.Name] = true
+	}
+	groupOptions := &gitlab.ListGroupMilestonesOptions{
+		State:       gitlab.String("active"),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	}
+	for {
+		var milestones []*gitlab.GroupMilestone
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			milestones, resp, err = c.gitlab.GroupMilestones.ListGroupMilestones(project.Namespace.ID, groupOptions, gitlab.WithContext(ctx))
+			return resp, err
+		})
 		if err != nil {
-			return issueTemplates, fmt.Errorf("could not read file %s: %w", file.Name(), err)
+			return m, err
+		}
+		for _, milestone := range milestones {
+			if seen[milestone.Title] {
+				continue
+			}
+			seen[milestone.Title] = true
+			m = append(m, issueMilestone{ID: milestone.ID, Name: milestone.Title, DueDate: milestone.DueDate, Group: true})
 		}
-		issueTemplates = append(issueTemplates, issueTemplate{
-			Name:    strings.TrimSuffix(file.Name(), ".md") + " [local]",
-			Content: b,
+		if resp.NextPage == 0 {
+			sortMilestones(m)
+			return m, nil
+		}
+		groupOptions.Page = resp.NextPage
+	}
+}
+
+type issueAssignee struct {
+	ID       int
+	Username string
+	Name     string
+}
+
+// getIssueAssignees lists the project members, including those inherited
+// from groups, who issues can be assigned to.
+func (c gitlabClient) getIssueAssignees(ctx context.Context, project *gitlab.Project) ([]issueAssignee, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	a := []issueAssignee{}
+	options := &gitlab.ListProjectMembersOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		var members []*gitlab.ProjectMember
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			members, resp, err = c.gitlab.ProjectMembers.ListAllProjectMembers(project.ID, options, gitlab.WithContext(ctx))
+			return resp, err
 		})
+		if err != nil {
+			return a, err
+		}
+		for _, member := range members {
+			a = append(a, issueAssignee{ID: member.ID, Username: member.Username, Name: member.Name})
+		}
+		if resp.NextPage == 0 {
+			return a, nil
+		}
+		options.Page = resp.NextPage
 	}
-	return issueTemplates, nil
 }
 
-func (c gitlabClient) getIssueTemplates(project *gitlab.Project) ([]issueTemplate, error) {
-	issueTemplates := []issueTemplate{
-		{
-			Name:    "BLANK",
-			Content: []byte{},
-		},
+// getGroupAssignees gets the members of the group at path, such as a team
+// owning an issue
+func (c gitlabClient) getGroupAssignees(ctx context.Context, path string) ([]issueAssignee, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	a := []issueAssignee{}
+	options := &gitlab.ListGroupMembersOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		var members []*gitlab.GroupMember
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			members, resp, err = c.gitlab.Groups.ListGroupMembers(path, options, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden) {
+			return nil, fmt.Errorf("group %s does not exist or you can not see its members", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get members of group %s: %w", path, err)
+		}
+		for _, member := range members {
+			a = append(a, issueAssignee{ID: member.ID, Username: member.Username, Name: member.Name})
+		}
+		if resp.NextPage == 0 {
+			return a, nil
+		}
+		options.Page = resp.NextPage
 	}
-	localIssueTemplates, err := getLocalIssueTemplates()
-	if err != nil {
-		return issueTemplates, fmt.Errorf("could not get local issue templates: %w", err)
+}
+
+// addAssignees appends those of more not already in assignees
+func addAssignees(assignees []issueAssignee, more ...issueAssignee) []issueAssignee {
+	for _, m := range more {
+		found := false
+		for _, a := range assignees {
+			if a.ID == m.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			assignees = append(assignees, m)
+		}
 	}
-	issueTemplates = append(issueTemplates, localIssueTemplates...)
-	nodes, _, err := c.gitlab.Repositories.ListTree(
-		project.ID,
-		&gitlab.ListTreeOptions{
-			Ref:  gitlab.String(project.DefaultBranch),
-			Path: gitlab.String(".gitlab/issue_templates"),
-		},
-	)
+	return assignees
+}
+
+// userAssignee is user as an assignee
+func userAssignee(user *gitlab.User) issueAssignee {
+	return issueAssignee{ID: user.ID, Username: user.Username, Name: user.Name}
+}
+
+// findAssignees looks up project members by username
+func (c gitlabClient) findAssignees(ctx context.Context, project *gitlab.Project, usernames []string) ([]issueAssignee, error) {
+	members, err := c.getIssueAssignees(ctx, project)
 	if err != nil {
-		return issueTemplates, fmt.Errorf("error fetching files from issue_templates: %w", err)
+		return nil, fmt.Errorf("could not get project members: %w", err)
 	}
-	for _, node := range nodes {
-		if !strings.HasSuffix(node.Path, ".md") {
-			continue
-		}
-		file, _, err := c.gitlab.RepositoryFiles.GetFile(
-			project.ID,
-			node.Path,
-			&gitlab.GetFileOptions{Ref: gitlab.String(project.DefaultBranch)},
-		)
-		if err != nil {
-			return issueTemplates, fmt.Errorf("error fetching file %s from issue_templates: %w", node.Path, err)
+	assignees := []issueAssignee{}
+	for _, username := range usernames {
+		username = strings.TrimPrefix(username, "@")
+		found := false
+		for _, member := range members {
+			if member.Username == username {
+				assignees = append(assignees, member)
+				found = true
+				break
+			}
 		}
-		content, err := base64.StdEncoding.DecodeString(file.Content)
-		if err != nil {
-			return issueTemplates, fmt.Errorf("error decoding file %s from issue_templates: %w", node.Path, err)
+		if !found {
+			return nil, fmt.Errorf("no project member with username %q", username)
 		}
-		issueTemplates = append(issueTemplates, issueTemplate{Name: strings.TrimSuffix(file.FileName, ".md"), Content: content})
 	}
-	return issueTemplates, nil
+	return assignees, nil
+}
+
+// gitConfigInstanceURL is the gitlab instance set by gitlab.host in the
+// repository's or global git config, for when the remote's host is not
+// where the API is served, eg. behind a CNAME. The host may be given as a
+// URL including any path prefix. It is empty when not set.
+func gitConfigInstanceURL(repository *git.Repository) (string, error) {
+	cfg, err := repository.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return "", fmt.Errorf("could not get git config: %w", err)
+	}
+	if !cfg.Raw.HasSection("gitlab") || !cfg.Raw.Section("gitlab").HasOption("host") {
+		return "", nil
+	}
+	host := strings.TrimSpace(cfg.Raw.Section("gitlab").Option("host"))
+	if host == "" || strings.Contains(host, "://") {
+		return host, nil
+	}
+	return "https://" + host, nil
 }
 
+// getEditor finds the editor as git does, repository may be nil when not in a
+// git repository.
 func getEditor(repository *git.Repository) (string, error) {
 	gitEditor := os.Getenv("GIT_EDITOR")
 	if gitEditor != "" {
 		return gitEditor, nil
 	}
-	cfg, err := repository.ConfigScoped(config.GlobalScope)
+	var cfg *config.Config
+	var err error
+	if repository != nil {
+		cfg, err = repository.ConfigScoped(config.GlobalScope)
+	} else {
+		cfg, err = config.LoadConfig(config.GlobalScope)
+	}
 	if err != nil {
 		return "", fmt.Errorf("could not get git config: %w", err)
 	}
@@ -191,180 +772,1027 @@ func getEditor(repository *git.Repository) (string, error) {
 	return "vi", nil
 }
 
-func (c gitlabClient) createIssueFromTemplate(repository *git.Repository, project *gitlab.Project, template issueTemplate) (issue *gitlab.Issue, err error) {
-	issue = &gitlab.Issue{}
-	file, err := ioutil.TempFile("", fmt.Sprintf("*_%s_%s_pre-submit.md", project.Name, template.Name))
-	if err != nil {
-		return issue, fmt.Errorf("could not create temporary issue-description file: %w", err)
-	}
-	buf := bytes.Buffer{}
-	buf.WriteByte('\n')
-	buf.WriteByte('\n')
-	buf.Write(template.Content)
-	_, err = file.Write(buf.Bytes())
+// editFile opens path in the user's editor and returns its content once the
+// editor exits.
+func editFile(repository *git.Repository, path string) ([]byte, error) {
+	editor, err := getEditor(repository)
 	if err != nil {
-		return issue, fmt.Errorf("could not prepopulate template: %w", err)
+		return nil, fmt.Errorf("could not get editor: %w", err)
 	}
-	err = file.Sync()
+	editorCommand, err := splitCommand(editor)
 	if err != nil {
-		return issue, fmt.Errorf("could not sync file to disk: %w", err)
+		return nil, fmt.Errorf("could not parse editor: %w", err)
 	}
-	editor, err := getEditor(repository)
-	if err != nil {
-		return issue, fmt.Errorf("could not get editor: %w", err)
-	}
-	editorCommand := strings.Split(editor, " ")
-	editorCommand = append(editorCommand, file.Name())
+	editorCommand = append(editorCommand, path)
 	cmd := exec.Command(editorCommand[0], editorCommand[1:]...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err = cmd.Run()
 	if err != nil {
-		return issue, fmt.Errorf("error running editor: %w", err)
+		return nil, fmt.Errorf("error running editor: %w", err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %w (%s)", err, path)
+	}
+	return content, nil
+}
+
+// editContent writes content to a new temporary file named after pattern
+// and opens it in the user's editor. The path of the file is returned with
+// the edited content so it can be removed once the content has been used.
+// The file is removed straight away if the content was not changed.
+func editContent(repository *git.Repository, pattern string, content []byte) (path string, edited []byte, err error) {
+	file, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer file.Close()
+	_, err = file.Write(content)
+	if err != nil {
+		return file.Name(), nil, fmt.Errorf("could not prepopulate template: %w", err)
+	}
+	err = file.Sync()
+	if err != nil {
+		return file.Name(), nil, fmt.Errorf("could not sync file to disk: %w", err)
+	}
+	started := time.Now()
+	edited, err = editFile(repository, file.Name())
+	if err != nil {
+		return file.Name(), nil, err
+	}
+	if bytes.Equal(edited, content) {
+		os.Remove(file.Name())
+		if time.Since(started) < time.Second {
+			return "", nil, fmt.Errorf("content has not been changed, the editor exited straight away so may need an option to wait, eg. code --wait")
+		}
+		return "", nil, fmt.Errorf("content has not been changed")
+	}
+	return file.Name(), edited, nil
+}
+
+// splitTitle takes the first line of content as a title and the remainder as
+// the description.
+func splitTitle(content []byte) (title, description string, err error) {
+	split := strings.SplitN(string(content), "\n", 2)
+	split[0] = strings.TrimSpace(split[0])
+	if len(split[0]) == 0 {
+		return "", "", fmt.Errorf("empty title")
+	}
+	if len(split) == 1 {
+		split = append(split, "")
+	}
+	return split[0], split[1], nil
+}
+
+// draftPattern names the temporary file an issue is written in, by project
+// and template name. It is kept as a draft if the issue is not created.
+const draftPattern = "*_%s_%s_pre-submit.md"
+
+// findDraft returns the most recently modified issue draft left for project,
+// or an empty string if there is none.
+func findDraft(project *gitlab.Project) string {
+	paths, _ := filepath.Glob(filepath.Join(os.TempDir(), fmt.Sprintf(draftPattern, project.Name, "*")))
+	draft := ""
+	var modTime time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(modTime) {
+			draft = path
+			modTime = info.ModTime()
+		}
+	}
+	return draft
+}
+
+// createIssueFromTemplate opens template in the editor and creates an issue
+// from the result. options gives any fields other than the title and
+// description to create the issue with, named by summary.
+func (c gitlabClient) createIssueFromTemplate(ctx context.Context, repository *git.Repository, project *gitlab.Project, template issueTemplate, options gitlab.CreateIssueOptions, summary issueSummary) (*gitlab.Issue, error) {
+	footer := expandTemplate([]byte(c.footer), repository, project)
+	template.Content = addFooter(expandTemplate(template.Content, repository, project), footer)
+	seed, commentChar := seedTemplate(template)
+	path, issueContent, err := editContent(repository, fmt.Sprintf(draftPattern, project.Name, template.fileName()), seed)
+	if err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("%w (draft saved to %s)", err, path)
+		}
+		return nil, err
+	}
+	return c.submitIssueDraft(ctx, project, path, issueContent, commentChar, footer, options, summary)
+}
+
+// createIssueWithoutEditor creates an issue from template as it is, for
+// templates which need no changes such as a recurring checklist. It is titled
+// title or else the template's first non-empty line.
+func (c gitlabClient) createIssueWithoutEditor(ctx context.Context, repository *git.Repository, project *gitlab.Project, template issueTemplate, title string, options gitlab.CreateIssueOptions, summary issueSummary) (*gitlab.Issue, error) {
+	content := expandTemplate(template.Content, repository, project)
+	description := string(content)
+	if title == "" {
+		var err error
+		title, description, err = splitTitle(bytes.TrimLeft(content, " \t\r\n"))
+		if err != nil {
+			return nil, fmt.Errorf("-no-edit needs -title or a template whose first line is the title")
+		}
 	}
-	issueContent, err := ioutil.ReadFile(file.Name())
+	return c.submitIssue(ctx, project, title, description, options, summary)
+}
+
+// submitIssue creates an issue with the title and description as they are.
+// Unless confirmed by -yes, the issue's fields are shown, named by summary,
+// and it is only created once you agree.
+func (c gitlabClient) submitIssue(ctx context.Context, project *gitlab.Project, title, description string, options gitlab.CreateIssueOptions, summary issueSummary) (*gitlab.Issue, error) {
+	if c.confirmCreate {
+		summary.print(os.Stderr, title, options)
+		if !confirm("Create issue?", false) {
+			return nil, fmt.Errorf("not creating the issue")
+		}
+	}
+	options.Title = gitlab.String(title)
+	options.Description = gitlab.String(appendAttachments(description, c.uploadAttachments(ctx, project, summary.Attachments)))
+	if c.dryRun {
+		return dryRunIssue(&options), nil
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var issue *gitlab.Issue
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		issue, resp, err = c.gitlab.Issues.CreateIssue(project.ID, &options, gitlab.WithContext(ctx))
+		return resp, err
+	})
 	if err != nil {
-		return issue, fmt.Errorf("could not read file: %w (%s)", err, file.Name())
+		return nil, fmt.Errorf("could not create gitlab issue: %w", err)
 	}
-	if bytes.Equal(issueContent, buf.Bytes()) {
-		return issue, fmt.Errorf("content of issue has not been changed")
+	return issue, nil
+}
+
+// createIssueFromDraft reopens a draft left by a previous run in the editor
+// and creates the issue from it.
+func (c gitlabClient) createIssueFromDraft(ctx context.Context, repository *git.Repository, project *gitlab.Project, path string, options gitlab.CreateIssueOptions, summary issueSummary) (*gitlab.Issue, error) {
+	issueContent, err := editFile(repository, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w (draft saved to %s)", err, path)
 	}
-	issueSplit := strings.SplitN(string(issueContent), "\n", 2)
-	if len(issueSplit) == 0 {
-		return issue, fmt.Errorf("empty issue content")
+	footer := expandTemplate([]byte(c.footer), repository, project)
+	return c.submitIssueDraft(ctx, project, path, issueContent, draftCommentChar(issueContent), footer, options, summary)
+}
+
+// submitIssueDraft creates an issue from the edited content of the draft at
+// path, removing the draft only once the issue has been created. Quick
+// actions such as /label ~bug in the description are left for gitlab to
+// apply. The footer is removed if left unchanged, and the summary's
+// attachments are uploaded and added after the edited description. Unless
+// confirmed by -yes, the issue's fields are shown, named by summary, and it
+// is only created once you agree.
+func (c gitlabClient) submitIssueDraft(ctx context.Context, project *gitlab.Project, path string, issueContent []byte, commentChar byte, footer []byte, options gitlab.CreateIssueOptions, summary issueSummary) (*gitlab.Issue, error) {
+	title, description, err := splitTitle(stripComments(issueContent, commentChar))
+	if err != nil {
+		return nil, fmt.Errorf("%w (draft saved to %s)", err, path)
 	}
-	if len(issueSplit[0]) == 0 {
-		return issue, fmt.Errorf("empty issue title (%s)", file.Name())
+	description = stripFooter(description, footer)
+	if c.dupCheck && !c.confirmNotDuplicate(ctx, project, title) {
+		return nil, fmt.Errorf("not creating a duplicate issue (draft saved to %s)", path)
 	}
-	if len(issueSplit) == 1 {
-		issueSplit = append(issueSplit, "")
+	if c.confirmCreate {
+		summary.print(os.Stderr, title, options)
+		if !confirm("Create issue?", false) {
+			return nil, fmt.Errorf("not creating the issue (draft saved to %s)", path)
+		}
 	}
-	issue, _, err = c.gitlab.Issues.CreateIssue(project.ID, &gitlab.CreateIssueOptions{Title: gitlab.String(issueSplit[0]), Description: gitlab.String(issueSplit[1])})
+	options.Title = gitlab.String(title)
+	options.Description = gitlab.String(appendAttachments(description, c.uploadAttachments(ctx, project, summary.Attachments)))
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		infof("Dry run, draft kept at %s", path)
+		return dryRunIssue(&options), nil
+	}
+	var issue *gitlab.Issue
+	_, err = c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		issue, resp, err = c.gitlab.Issues.CreateIssue(project.ID, &options, gitlab.WithContext(ctx))
+		return resp, err
+	})
 	if err != nil {
-		return issue, fmt.Errorf("could not create gitlab issue: %w (%s)", err, file.Name())
+		return nil, fmt.Errorf("could not create gitlab issue: %w (draft saved to %s)", err, path)
 	}
-	err = os.Remove(file.Name()) // remove file once sure of success
+	err = os.Remove(path) // remove file once sure of success
 	return issue, err
 }
 
-func (c gitlabClient) setIssueLabelsMilestones(project *gitlab.Project, issue *gitlab.Issue, labels []issueLabel, milestone issueMilestone) error {
-	var labelNames []string
+// selectionOptions are the options to create an issue with the selected
+// labels, milestone and assignees, and the summary naming them. Those not
+// selected are left unset, so nothing is sent for them.
+func selectionOptions(labels []issueLabel, milestone *issueMilestone, assignees []issueAssignee) (gitlab.CreateIssueOptions, issueSummary) {
+	options := gitlab.CreateIssueOptions{}
+	summary := issueSummary{}
+	for _, a := range assignees {
+		options.AssigneeIDs = append(options.AssigneeIDs, a.ID)
+		summary.Assignees = append(summary.Assignees, a.Username)
+	}
 	for _, l := range labels {
-		if l.ID != 0 {
-			labelNames = append(labelNames, l.Name)
-		}
+		options.Labels = append(options.Labels, l.Name)
 	}
-	options := &gitlab.UpdateIssueOptions{AddLabels: labelNames}
-	if milestone.ID != 0 {
+	if milestone != nil {
 		options.MilestoneID = gitlab.Int(milestone.ID)
+		summary.Milestone = milestone.Name
 	}
-	_, _, err := c.gitlab.Issues.UpdateIssue(project.ID, issue.IID, options)
-	return err
+	return options, summary
 }
 
-func main() {
-	currentFullPath, err := filepath.Abs(".")
+// issueOptions are the fields of an issue which can be given as flags
+type issueOptions struct {
+	Title       string
+	Description string
+	Labels      []string
+	Milestone   string
+	// Assignees are usernames, with or without a leading @
+	Assignees []string
+	// AssignMe adds the current user to the assignees
+	AssignMe bool
+	// AssigneeGroup is the path of a group whose members are all assigned
+	AssigneeGroup string
+	DueDate       *gitlab.ISOTime
+	Weight        *int
+	Confidential  bool
+	// Attachments are the paths of files to upload and link at the end of
+	// the description
+	Attachments []string
+}
+
+// parseDueDate parses a YYYY-MM-DD date, returning nil for an empty string
+func parseDueDate(s string) (*gitlab.ISOTime, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
 	if err != nil {
-		log.Fatalf("Could not get full path of current dir: %s", err)
+		return nil, fmt.Errorf("invalid due date %q, expected YYYY-MM-DD", s)
 	}
-	repo, err := findRepo(currentFullPath)
+	d := gitlab.ISOTime(t)
+	return &d, nil
+}
+
+// parseWeight parses a non-negative issue weight, returning nil for an empty
+// string
+func parseWeight(s string) (*int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	weight, err := strconv.Atoi(s)
+	if err != nil || weight < 0 {
+		return nil, fmt.Errorf("invalid weight %q, expected a non-negative integer", s)
+	}
+	return &weight, nil
+}
+
+// promptWeight asks for a weight until a valid weight or nothing is given
+func promptWeight() *int {
+	for {
+		weight, err := parseWeight(prompt("Weight (blank for none):"))
+		if err == nil {
+			return weight
+		}
+		log.Println(err)
+	}
+}
+
+// promptDueDate asks for a due date until a valid date or nothing is given
+func promptDueDate() *gitlab.ISOTime {
+	return promptDate("Due date (YYYY-MM-DD, blank for none):")
+}
+
+// promptDate asks question until it is answered with a valid YYYY-MM-DD
+// date or left blank
+func promptDate(question string) *gitlab.ISOTime {
+	for {
+		date, err := parseDueDate(prompt(question))
+		if err == nil {
+			return date
+		}
+		log.Println(err)
+	}
+}
+
+// createIssue creates an issue without any interaction, looking up the
+// milestone and assignees by name when they are given.
+func (c gitlabClient) createIssue(ctx context.Context, project *gitlab.Project, opts issueOptions) (*gitlab.Issue, error) {
+	options := &gitlab.CreateIssueOptions{
+		Title:   gitlab.String(opts.Title),
+		DueDate: opts.DueDate,
+		Weight:  opts.Weight,
+	}
+	if opts.Confidential {
+		options.Confidential = gitlab.Bool(true)
+	}
+	if len(opts.Labels) > 0 {
+		options.Labels = gitlab.Labels(opts.Labels)
+	}
+	if opts.Milestone != "" {
+		milestones, err := c.getIssueMilestones(ctx, project)
+		if err != nil {
+			return nil, fmt.Errorf("could not get milestones: %w", err)
+		}
+		for _, m := range milestones {
+			if m.Name == opts.Milestone {
+				options.MilestoneID = gitlab.Int(m.ID)
+				break
+			}
+		}
+		if options.MilestoneID == nil {
+			return nil, fmt.Errorf("no active milestone named %q", opts.Milestone)
+		}
+	}
+	assignees := []issueAssignee{}
+	if len(opts.Assignees) > 0 {
+		var err error
+		assignees, err = c.findAssignees(ctx, project, opts.Assignees)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.AssignMe {
+		user, err := c.currentUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+		assignees = addAssignees(assignees, userAssignee(user))
+	}
+	if opts.AssigneeGroup != "" {
+		members, err := c.getGroupAssignees(ctx, opts.AssigneeGroup)
+		if err != nil {
+			return nil, err
+		}
+		assignees = addAssignees(assignees, members...)
+	}
+	for _, a := range assignees {
+		options.AssigneeIDs = append(options.AssigneeIDs, a.ID)
+	}
+	// uploaded last, so nothing is uploaded for an issue which is not created
+	options.Description = gitlab.String(appendAttachments(opts.Description, c.uploadAttachments(ctx, project, opts.Attachments)))
+	if c.dryRun {
+		issue := dryRunIssue(options)
+		if opts.Milestone != "" {
+			issue.Milestone = &gitlab.Milestone{Title: opts.Milestone}
+		}
+		addDryRunAssignees(issue, assignees)
+		return issue, nil
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var issue *gitlab.Issue
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		issue, resp, err = c.gitlab.Issues.CreateIssue(project.ID, options, gitlab.WithContext(ctx))
+		return resp, err
+	})
 	if err != nil {
-		log.Fatalf("Error finding git repo in working directory: %s. Please specify project", err)
+		return nil, fmt.Errorf("could not create gitlab issue: %w", err)
+	}
+	return issue, nil
+}
+
+// dryRunIssue is the issue that would be created with options
+func dryRunIssue(options *gitlab.CreateIssueOptions) *gitlab.Issue {
+	issue := &gitlab.Issue{State: "dry run", Labels: options.Labels, DueDate: options.DueDate}
+	if options.Title != nil {
+		issue.Title = *options.Title
+	}
+	if options.Description != nil {
+		issue.Description = *options.Description
+	}
+	if options.Confidential != nil {
+		issue.Confidential = *options.Confidential
+	}
+	if options.Weight != nil {
+		issue.Weight = *options.Weight
+	}
+	return issue
+}
+
+func addDryRunAssignees(issue *gitlab.Issue, assignees []issueAssignee) {
+	for _, a := range assignees {
+		issue.Assignees = append(issue.Assignees, &gitlab.IssueAssignee{ID: a.ID, Username: a.Username, Name: a.Name})
+	}
+}
+
+// shareURL opens webURL in the browser and/or copies it to the clipboard
+func shareURL(webURL string, open, copy bool) {
+	if open {
+		err := openBrowser(webURL)
+		if err != nil {
+			log.Printf("%s", err)
+		}
+	}
+	if copy {
+		err := copyToClipboard(webURL)
+		if err != nil {
+			log.Printf("%s", err)
+			return
+		}
+		infof("copied %s to the clipboard", webURL)
+	}
+}
+
+// reportIssue prints the issue to stdout, as JSON for -output json, in full
+// for a dry run or otherwise just its URL, then shares the URL of a created
+// issue.
+func reportIssue(issue *gitlab.Issue, output string, dryRun, open, copy bool) {
+	if output == "json" {
+		err := writeIssueJSON(os.Stdout, issue)
+		if err != nil {
+			log.Printf("could not write issue: %s", err)
+		}
+	} else if dryRun {
+		printIssue(os.Stdout, issue, false)
+	} else {
+		fmt.Println(issue.WebURL)
+	}
+	if !dryRun {
+		shareURL(issue.WebURL, open, copy)
+	}
+}
+
+// stringsFlag collects every value of a flag that may be repeated
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	err := run()
+	switch {
+	case err == nil:
+	case errors.Is(err, errAborted):
+		log.Println("aborted")
+	default:
+		log.Printf("%s", describeErr(err))
 	}
+	os.Exit(exitCode(err))
+}
 
-	originRemote, err := repo.Remote("origin")
+// run is the command line, returning the error to exit with
+func run() error {
+	remoteName := flag.String("remote", "origin", "git remote to find the gitlab project from")
+	projectFlag := flag.String("project", "", "gitlab project path as group/name, skips finding the project from the git remote")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout for each request to gitlab")
+	dryRun := flag.Bool("dry-run", false, "print the issue instead of creating it, changing nothing on gitlab")
+	retries := flag.Int("retries", 3, "times to retry a request rate limited by gitlab")
+	noRecall := flag.Bool("no-recall", false, "do not offer or remember the labels last used in the project")
+	noDupCheck := flag.Bool("no-dup-check", false, "do not look for open issues like the one written in the editor before creating it")
+	yes := flag.Bool("yes", false, "create the issue written in the editor without showing its fields and asking first")
+	noCache := flag.Bool("no-cache", false, "always look up the project instead of using the cached project")
+	baseURL := flag.String("base-url", "", "gitlab instance URL including any path prefix, overrides GITLAB_URL, gitlab.host in the git config and the remote's host")
+	caCertFlag := flag.String("ca-cert", "", "PEM file of CA certificates to trust for gitlab, overrides GITLAB_CA_CERT")
+	insecure := flag.Bool("insecure", false, "do not verify gitlab's TLS certificate, only for development instances")
+	proxy := flag.String("proxy", "", "URL of the proxy to gitlab, overrides HTTPS_PROXY, HTTP_PROXY and NO_PROXY")
+	targetProject := flag.String("target-project", "", "group/name of the project to create the issue in, on the same gitlab, instead of the current repository's")
+	tokenFlag := flag.String("token", "", "gitlab token, overrides GITLAB_TOKEN_FILE, config and GITLAB_TOKEN")
+	title := flag.String("title", "", "issue title, skips the editor and all prompts when set")
+	description := flag.String("description", "", "issue description, used with -title")
+	noEdit := flag.Bool("no-edit", false, "create the issue from the selected template as it is, titled by -title or the template's first line")
+	issueFile := flag.String("file", "", "file to read the issue from instead of the editor, the first line as the title and the rest as the description")
+	milestoneName := flag.String("milestone", "", "issue milestone title, used with -title")
+	var labelNames stringsFlag
+	flag.Var(&labelNames, "label", "issue label, used with -title (may be repeated)")
+	var labelMatches stringsFlag
+	flag.Var(&labelMatches, "label-match", "add every project label whose name contains this, ignoring case (may be repeated)")
+	var scopedNames stringsFlag
+	flag.Var(&scopedNames, "scoped", "scoped label like priority::high, created if it does not exist (may be repeated)")
+	var assigneeNames stringsFlag
+	flag.Var(&assigneeNames, "assignee", "issue assignee as @username, used with -title (may be repeated)")
+	var attachPaths stringsFlag
+	flag.Var(&attachPaths, "attach", "file to upload and link at the end of the issue description (may be repeated)")
+	assigneeGroup := flag.String("assignee-group", "", "group whose members are assigned, all of them with -title or those selected")
+	confidential := flag.Bool("confidential", false, "create a confidential issue, asked when not set with ask_confidential in the config file")
+	openFlag := flag.Bool("open", false, "open the created or selected issue in the browser")
+	var mine bool
+	flag.BoolVar(&mine, "mine", false, "assign the issue to yourself instead of selecting assignees")
+	flag.BoolVar(&mine, "assign-me", false, "same as -mine")
+	copyFlag := flag.Bool("copy", false, "copy the created issue's URL to the clipboard")
+	due := flag.String("due", "", "issue due date as YYYY-MM-DD, prompted for when not set with ask_due_date in the config file")
+	relatesTo := flag.String("relates-to", "", "IID of an issue to link the issue to, asked when not set with ask_link in the config file")
+	linkType := flag.String("link-type", "relates_to", "type of the -relates-to link: "+strings.Join(linkTypes, ", "))
+	branchFlag := flag.Bool("branch", false, "create a branch for the issue from the default branch and check it out")
+	withDiff := flag.Bool("with-diff", false, "attach the uncommitted changes, git diff HEAD, to the issue as a comment")
+	epicFlag := flag.Bool("epic", false, "select an epic to add the issue to, needs gitlab premium")
+	estimateFlag := flag.String("estimate", "", "time estimate for the issue, eg. 2h or 1d 4h")
+	spentFlag := flag.String("spent", "", "time already spent on the issue, eg. 30m")
+	weightFlag := flag.String("weight", "", "issue weight, prompted for when not set with ask_weight in the config file")
+	flag.BoolVar(&quiet, "quiet", false, "only log warnings and errors, leaving the created issue's URL on stdout")
+	refreshTemplates := flag.Bool("refresh-templates", false, "fetch the project's templates instead of using those cached")
+	templateFlag := flag.String("template", "", "issue template to use instead of selecting one")
+	output := flag.String("output", "text", "format of the created issue: text or json")
+	debug := flag.Bool("debug", false, "log each request to gitlab and its response status")
+	versionFlag := flag.Bool("version", false, "print the version and exit")
+	flag.Usage = usage
+	flag.Parse()
+	if *versionFlag {
+		printVersion(os.Stdout)
+		return nil
+	}
+	switch *output {
+	case "text":
+	case "json":
+		quiet = true
+	default:
+		return usageErrorf("unknown -output %q, expected text or json", *output)
+	}
+	// completion is left out of the usage, and needs no gitlab project
+	if flag.Arg(0) == "completion" {
+		err := printCompletion(os.Stdout, flag.Arg(1), commandNames(), flag.CommandLine)
+		if err != nil {
+			return usageError{err}
+		}
+		return nil
+	}
+	if _, ok := findCommand(flag.Arg(0)); !ok && flag.NArg() > 0 && flag.Arg(0) != issueCommand {
+		return usageErrorf("unknown command %q, see -help", flag.Arg(0))
+	}
+	dueDate, err := parseDueDate(*due)
+	if err != nil {
+		return usageError{err}
+	}
+	weight, err := parseWeight(*weightFlag)
 	if err != nil {
-		log.Fatalf("Error getting remote origin: %s", err)
+		return usageError{err}
+	}
+	for _, name := range scopedNames {
+		if err := validScopedLabel(name); err != nil {
+			return usageError{err}
+		}
+	}
+	estimate, err := parseTimeDuration("estimate", *estimateFlag)
+	if err != nil {
+		return usageError{err}
+	}
+	spent, err := parseTimeDuration("spent", *spentFlag)
+	if err != nil {
+		return usageError{err}
+	}
+	relatedIID := 0
+	if *relatesTo != "" {
+		relatedIID, err = parseIID(*relatesTo)
+		if err != nil {
+			return usageError{err}
+		}
+	}
+	if !validLinkType(*linkType) {
+		return usageErrorf("unknown -link-type %q, expected one of %s", *linkType, strings.Join(linkTypes, ", "))
+	}
+	if *issueFile != "" {
+		if *noEdit {
+			return usageErrorf("-file and -no-edit can not both be given")
+		}
+		if *title != "" {
+			return usageErrorf("-file and -title can not both be given")
+		}
+		content, err := ioutil.ReadFile(*issueFile)
+		if err != nil {
+			return fmt.Errorf("could not read -file: %w", err)
+		}
+		if len(bytes.TrimSpace(content)) == 0 {
+			return usageErrorf("-file %s is empty", *issueFile)
+		}
+		*title, *description, err = splitTitle(content)
+		if err != nil {
+			return fmt.Errorf("could not read issue from %s: %w", *issueFile, err)
+		}
 	}
-	origin := originRemote.Config().URLs[0]
-	log.Printf("Origin URL: %s", origin)
 
-	originURL, err := url.Parse(origin)
+	currentFullPath, err := filepath.Abs(".")
 	if err != nil {
-		log.Fatalf("Error parsing URL for origin %s: %s", origin, err)
+		return fmt.Errorf("could not get full path of current dir: %w", err)
+	}
+	// without a repository the editor is found from the global git config
+	// and environment, and the client needs -project
+	repo, err := findRepo(currentFullPath)
+	if err == errNoRepo {
+		err = nil
 	}
-	gitlabBaseURL := url.URL{Scheme: "https", Host: originURL.Host, Path: "/api/v4"}
-	// TODO add timeout or context to client upstream
-	cli, err := gitlab.NewClient(os.Getenv("GITLAB_TOKEN"), gitlab.WithBaseURL(gitlabBaseURL.String()))
 	if err != nil {
-		log.Fatalf("Failed to create client: %s", err)
+		return err
 	}
-	client := gitlabClient{
-		gitlab: cli,
+	if *branchFlag && repo == nil {
+		return usageErrorf("-branch needs a git repository to check the branch out in")
 	}
-	project, err := client.getProjectFromOrigin(originURL)
+	diff := ""
+	if *withDiff {
+		if repo == nil {
+			return usageErrorf("-with-diff needs a git repository to diff")
+		}
+		diff, err = worktreeDiff(repo)
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			log.Printf("No uncommitted changes to attach")
+		}
+	}
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Failed to get project from origin URL: %s", err)
+		return fmt.Errorf("failed to load config: %w", err)
 	}
-	log.Printf("Found project: %s", project.HTTPURLToRepo)
-	templates, err := client.getIssueTemplates(project)
+	client, err := newClient(cfg, repo, clientOptions{
+		BaseURL:          *baseURL,
+		Project:          *projectFlag,
+		Remote:           *remoteName,
+		Token:            *tokenFlag,
+		CACertFile:       *caCertFlag,
+		Insecure:         *insecure,
+		Proxy:            *proxy,
+		Timeout:          *timeout,
+		Retries:          *retries,
+		DryRun:           *dryRun,
+		DupCheck:         !*noDupCheck,
+		ConfirmCreate:    !*yes,
+		Debug:            *debug,
+		RefreshTemplates: *refreshTemplates,
+	})
 	if err != nil {
-		log.Fatalf("Failed to get issue templates for project: %s", err)
+		return err
 	}
-	if len(templates) == 0 {
-		log.Println("No issue templates present")
+	projectPath := client.projectPath
+	// the remote's host may not be the instance the project is looked up on
+	instance := cacheInstance(client.baseURL)
+	ctx := context.Background()
+	var user *gitlab.User
+	if !client.jobToken {
+		user, err = client.currentUser(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+		infof("Authenticated as: @%s", user.Username)
+	}
+	if mine && user == nil {
+		return usageErrorf("-mine needs a token for a user, CI_JOB_TOKEN can not look up the current user")
+	}
+	var project *gitlab.Project
+	if *noCache {
+		project, _, err = client.findProject(ctx, projectPath)
+	} else {
+		project, err = client.getCachedProject(ctx, instance, projectPath)
 	}
-	idx, err := fuzzyfinder.Find(
-		templates,
-		func(i int) string {
-			return templates[i].Name
-		},
-	)
 	if err != nil {
-		log.Fatalf("Failed to select template: %s", err)
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			return errAborted
+		}
+		return fmt.Errorf("failed to get project from origin URL: %w", err)
 	}
-	log.Printf("Selected template: %s", templates[idx].Name)
-	labels, err := client.getIssueLabels(project)
+	infof("Found project: %s", project.PathWithNamespace)
+	if cmd, ok := findCommand(flag.Arg(0)); ok {
+		err = cmd.run(commandEnv{
+			ctx:     ctx,
+			client:  client,
+			repo:    repo,
+			project: project,
+			user:    user,
+			open:    *openFlag || cfg.Open,
+		}, flag.Args()[1:])
+		if err != nil {
+			if errors.Is(err, fuzzyfinder.ErrAbort) {
+				return errAborted
+			}
+			return err
+		}
+		return nil
+	}
+	// the branch is made in the repository's project even when the issue
+	// is created in another
+	repoProject := project
+	if *targetProject != "" {
+		projectPath = strings.Trim(*targetProject, "/")
+		project, err = client.getProjectFromOrigin(ctx, projectPath)
+		if err != nil {
+			return fmt.Errorf("failed to get -target-project: %w", err)
+		}
+		infof("Creating the issue in: %s", project.PathWithNamespace)
+	}
+	if issuesDisabled(project) {
+		return fmt.Errorf("%w for %s, use -target-project to create the issue in another project", errIssuesDisabled, project.PathWithNamespace)
+	}
+	if readsIssueFromStdin(*title, *noEdit) {
+		content, err := readStdin()
+		if err != nil {
+			return err
+		}
+		*title, *description, err = splitTitle(content)
+		if err != nil {
+			return fmt.Errorf("could not read issue from stdin: %w", err)
+		}
+	}
+	scopedLabels, err := client.ensureLabels(ctx, project, scopedNames)
 	if err != nil {
-		log.Printf("Failed to get issue labels for project: %s", err)
+		return err
+	}
+	matchedLabels, err := client.findLabelsContaining(ctx, project, labelMatches)
+	if err != nil {
+		return err
+	}
+	// added to those given or selected, scoped labels last so they replace
+	// any matched of the same scope
+	flagLabels := append(matchedLabels, scopedLabels...)
+	// -no-edit still selects the template, so only skips the editor
+	if *title != "" && !*noEdit {
+		issue, err := client.createIssue(ctx, project, issueOptions{
+			Title:         *title,
+			Description:   *description,
+			Attachments:   attachPaths,
+			Labels:        withLabelNames(labelNames, flagLabels),
+			Milestone:     *milestoneName,
+			Assignees:     assigneeNames,
+			AssignMe:      mine,
+			AssigneeGroup: *assigneeGroup,
+			DueDate:       dueDate,
+			Weight:        weight,
+			Confidential:  *confidential,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create issue: %w", err)
+		}
+		if relatedIID != 0 {
+			err = client.linkIssue(ctx, project, issue, relatedIID, *linkType)
+			if err != nil {
+				log.Printf("%s", describeErr(err))
+			}
+		}
+		err = client.trackTime(ctx, project, issue, estimate, spent)
+		if err != nil {
+			log.Printf("%s", describeErr(err))
+		}
+		if diff != "" {
+			err = client.attachDiff(ctx, project, issue, diff)
+			if err != nil {
+				log.Printf("%s", describeErr(err))
+			}
+		}
+		// the issue is created, so it is reported before failing to branch
+		var branchErr error
+		if *branchFlag {
+			var branch string
+			branch, branchErr = client.startIssueBranch(ctx, repo, *remoteName, repoProject, issue)
+			if branchErr == nil {
+				log.Printf("Checked out branch %s", branch)
+			}
+		}
+		reportIssue(issue, *output, *dryRun, *openFlag || cfg.Open, *copyFlag)
+		return branchErr
+	}
+	draft := findDraft(project)
+	resume := draft != "" && !*noEdit && confirm(fmt.Sprintf("Resume draft for %s at %s?", project.PathWithNamespace, draft), true)
+	var template issueTemplate
+	if !resume {
+		templates, err := client.getTemplates(ctx, project, issueTemplatesDir)
+		if err != nil {
+			return fmt.Errorf("failed to get issue templates for %s: %w", project.PathWithNamespace, err)
+		}
+		if len(templates) == 0 {
+			infof("No issue templates in %s", project.PathWithNamespace)
+		}
+		name := *templateFlag
+		if name == "" {
+			name = cfg.DefaultTemplate
+		}
+		found := false
+		if name != "" {
+			template, found = findTemplate(templates, name)
+			if !found {
+				log.Printf("No issue template named %q, select one instead", name)
+			}
+		}
+		if !found {
+			idx, err := fuzzyfinder.Find(
+				templates,
+				func(i int) string {
+					return templates[i].Name
+				},
+				templatePreview(templates),
+			)
+			if err != nil {
+				if errors.Is(err, fuzzyfinder.ErrAbort) {
+					return errAborted
+				}
+				return fmt.Errorf("failed to select template: %w", err)
+			}
+			template = templates[idx]
+		}
+		infof("Selected template: %s", template.Name)
+	}
+	labels, err := client.getIssueLabels(ctx, project)
+	if err != nil {
+		log.Printf("Failed to get issue labels for %s: %s", project.PathWithNamespace, describeErr(err))
 	}
 	if len(labels) == 0 {
-		log.Println("No issue labels present")
+		infof("No issue labels in %s", project.PathWithNamespace)
 	}
+	sortLabelsByScope(labels)
 
-	milestones, err := client.getIssueMilestones(project)
+	milestones, err := client.getIssueMilestones(ctx, project)
 	if err != nil {
-		log.Printf("Failed to get issue milestones for project: %s", err)
+		log.Printf("Failed to get issue milestones for %s: %s", project.PathWithNamespace, describeErr(err))
 	}
 	if len(milestones) == 0 {
-		log.Println("No issue milestones present")
+		infof("No issue milestones in %s", project.PathWithNamespace)
 	}
 
-	issue, err := client.createIssueFromTemplate(repo, project, templates[idx])
-	if err != nil {
-		log.Fatalf("could not create issue: %s", err)
+	var assignees []issueAssignee
+	if *assigneeGroup != "" {
+		assignees, err = client.getGroupAssignees(ctx, *assigneeGroup)
+		if err != nil {
+			return err
+		}
+	} else if !mine {
+		assignees, err = client.getIssueAssignees(ctx, project)
+		if err != nil {
+			log.Printf("Failed to get members of %s: %s", project.PathWithNamespace, describeErr(err))
+		}
 	}
-	log.Printf("created: %s", issue.WebURL)
-	selectedMilestone := noMilestone
-	if len(milestones) > 0 {
-		milestoneIdx, _ := fuzzyfinder.Find(
-			milestones,
-			func(i int) string {
-				return milestones[i].Name
-			},
-		)
-		selectedMilestone = milestones[milestoneIdx]
+
+	var selectedMilestone *issueMilestone
+	milestoneChoices := append([]issueMilestone{newMilestone}, milestones...)
+	milestoneIdx, err := fuzzyfinder.Find(
+		milestoneChoices,
+		func(i int) string {
+			return milestoneChoices[i].String()
+		},
+	)
+	if selected("milestone", err) {
+		milestone := milestoneChoices[milestoneIdx]
+		if milestone == newMilestone {
+			milestone, err = client.promptNewMilestone(ctx, project)
+		}
+		if err != nil {
+			log.Printf("%s", describeErr(err))
+		} else {
+			selectedMilestone = &milestone
+		}
+	}
+	var selectedLabels []issueLabel
+	labelChoices := []issueLabel{newLabel}
+	recalled := []issueLabel{}
+	if !*noRecall {
+		recalled = matchLabels(labels, recallLabels(instance, projectPath))
+	}
+	lastUsed := recalledLabels(recalled)
+	if len(recalled) > 0 {
+		labelChoices = append(labelChoices, lastUsed)
+	}
+	labelChoices = append(labelChoices, labels...)
+	labelIdxs, err := fuzzyfinder.FindMulti(
+		labelChoices,
+		func(i int) string {
+			return labelChoices[i].String()
+		},
+	)
+	if !selected("labels", err) {
+		labelIdxs = nil
+	}
+	for _, idx := range labelIdxs {
+		label := labelChoices[idx]
+		switch label {
+		case newLabel:
+			label, err = client.promptNewLabel(ctx, project)
+			if err != nil {
+				log.Printf("%s", describeErr(err))
+				continue
+			}
+		case lastUsed:
+			for _, l := range recalled {
+				selectedLabels = withLabel(selectedLabels, l)
+			}
+			continue
+		}
+		selectedLabels = withLabel(selectedLabels, label)
+	}
+	for _, label := range flagLabels {
+		selectedLabels = withLabel(selectedLabels, label)
 	}
-	selectedLabels := noLabels
-	if len(labels) > 0 {
-		labelIdxs, err := fuzzyfinder.FindMulti(
-			labels,
+
+	if dueDate == nil && cfg.AskDueDate {
+		dueDate = promptDueDate()
+	}
+	selectedAssignees := []issueAssignee{}
+	if mine {
+		selectedAssignees = append(selectedAssignees, userAssignee(user))
+	}
+	if len(assignees) > 0 {
+		assigneeIdxs, err := fuzzyfinder.FindMulti(
+			assignees,
 			func(i int) string {
-				return fmt.Sprintf("%s: %s", labels[i].Name, labels[i].Description)
+				return fmt.Sprintf("@%s: %s", assignees[i].Username, assignees[i].Name)
 			},
 		)
-		selectedLabels = []issueLabel{}
-		if err != nil {
-			selectedLabels = noLabels
+		if !selected("assignees", err) {
+			assigneeIdxs = nil
 		}
-		for _, idx := range labelIdxs {
-			selectedLabels = append(selectedLabels, labels[idx])
+		for _, idx := range assigneeIdxs {
+			selectedAssignees = addAssignees(selectedAssignees, assignees[idx])
 		}
 	}
 
-	err = client.setIssueLabelsMilestones(project, issue, selectedLabels, selectedMilestone)
+	createOptions, summary := selectionOptions(selectedLabels, selectedMilestone, selectedAssignees)
+	createOptions.DueDate = dueDate
+	if *confidential || (cfg.AskConfidential && confirm("Make the issue confidential?", false)) {
+		createOptions.Confidential = gitlab.Bool(true)
+	}
+	if weight == nil && cfg.AskWeight {
+		weight = promptWeight()
+	}
+	createOptions.Weight = weight
+	summary.Attachments = attachPaths
+	var issue *gitlab.Issue
+	if template.Form && !resume {
+		issue, err = client.createIssueFromForm(ctx, project, template, *title, createOptions, summary)
+	} else if *noEdit {
+		issue, err = client.createIssueWithoutEditor(ctx, repo, project, template, *title, createOptions, summary)
+	} else if resume {
+		issue, err = client.createIssueFromDraft(ctx, repo, project, draft, createOptions, summary)
+	} else {
+		issue, err = client.createIssueFromTemplate(ctx, repo, project, template, createOptions, summary)
+	}
+	if err != nil {
+		return fmt.Errorf("could not create issue: %w", err)
+	}
+	if !*dryRun {
+		infof("created in %s: %s", project.PathWithNamespace, issue.WebURL)
+	} else {
+		if selectedMilestone != nil {
+			issue.Milestone = &gitlab.Milestone{ID: selectedMilestone.ID, Title: selectedMilestone.Name}
+		}
+		addDryRunAssignees(issue, selectedAssignees)
+	}
+	if !*noRecall && !*dryRun {
+		names := []string{}
+		for _, l := range selectedLabels {
+			names = append(names, l.Name)
+		}
+		if len(names) > 0 {
+			err = rememberLabels(instance, projectPath, project, names)
+			if err != nil {
+				log.Printf("Could not remember labels: %s", err)
+			}
+		}
+	}
+	if *epicFlag || cfg.Epics {
+		epics, err := client.getGroupEpics(ctx, project)
+		if err != nil {
+			log.Printf("Not adding the issue to an epic: %s", describeErr(err))
+		}
+		if len(epics) > 0 {
+			epicIdx, err := fuzzyfinder.Find(
+				epics,
+				func(i int) string {
+					return epics[i].String()
+				},
+			)
+			if selected("epic", err) {
+				// the issue is created, so it is still reported
+				err = client.assignEpic(ctx, issue, epics[epicIdx])
+				if err != nil {
+					log.Printf("Not adding the issue to an epic: %s", describeErr(err))
+				}
+			}
+		}
+	}
+	if relatedIID == 0 && cfg.AskLink && confirm("Link the issue to another issue?", false) {
+		relatedIID, err = client.selectRelatedIssue(ctx, project, issue)
+		if err != nil && !errors.Is(err, fuzzyfinder.ErrAbort) {
+			log.Printf("Not linking the issue: %s", describeErr(err))
+		}
+	}
+	if relatedIID != 0 {
+		err = client.linkIssue(ctx, project, issue, relatedIID, *linkType)
+		if err != nil {
+			log.Printf("%s", describeErr(err))
+		}
+	}
+	err = client.trackTime(ctx, project, issue, estimate, spent)
 	if err != nil {
-		log.Fatalf("could not add labels/milestones to issue: %s", err)
+		log.Printf("%s", describeErr(err))
+	}
+	if diff != "" {
+		err = client.attachDiff(ctx, project, issue, diff)
+		if err != nil {
+			log.Printf("%s", describeErr(err))
+		}
+	}
+	// the issue is created, so it is reported before failing to branch
+	var branchErr error
+	if *branchFlag {
+		var branch string
+		branch, branchErr = client.startIssueBranch(ctx, repo, *remoteName, repoProject, issue)
+		if branchErr == nil {
+			log.Printf("Checked out branch %s", branch)
+		}
 	}
+	reportIssue(issue, *output, *dryRun, *openFlag || cfg.Open, *copyFlag)
+	return branchErr
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiUnderline = "\x1b[4m"
+	ansiCyan      = "\x1b[36m"
+)
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listPattern     = regexp.MustCompile(`^(\s*)[-*+]\s+(\[[ xX]\]\s+)?(.*)$`)
+	rulePattern     = regexp.MustCompile(`^\s*([-*_]\s*){3,}$`)
+	boldPattern     = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	codeSpanPattern = regexp.MustCompile("`([^`]+)`")
+	linkPattern     = regexp.MustCompile(`!?\[([^\]]*)\]\(([^)\s]+)[^)]*\)`)
+)
+
+// renderToStdout reports whether markdown printed to stdout is rendered,
+// when stdout is a terminal and NO_COLOR is not set
+func renderToStdout() bool {
+	return term.IsTerminal(int(os.Stdout.Fd())) && os.Getenv("NO_COLOR") == ""
+}
+
+// renderMarkdown formats the markdown of an issue description for the
+// terminal, highlighting headings, emphasis and code and drawing lists,
+// quotes and rules. It covers the markdown common in issues rather than all
+// of it, leaving what it does not understand as written.
+func renderMarkdown(markdown string) string {
+	out := strings.Builder{}
+	fence := ""
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				continue
+			}
+			out.WriteString("    " + ansiDim + line + ansiReset + "\n")
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			style := ansiBold
+			if len(m[1]) <= 2 {
+				style += ansiUnderline
+			}
+			out.WriteString(style + renderInline(m[2]) + ansiReset + "\n")
+			continue
+		}
+		if rulePattern.MatchString(line) {
+			out.WriteString(ansiDim + strings.Repeat("─", 40) + ansiReset + "\n")
+			continue
+		}
+		if m := listPattern.FindStringSubmatch(line); m != nil {
+			bullet := "•"
+			switch strings.ToLower(strings.TrimSpace(m[2])) {
+			case "[ ]":
+				bullet = "☐"
+			case "[x]":
+				bullet = "☑"
+			}
+			out.WriteString(m[1] + bullet + " " + renderInline(m[3]) + "\n")
+			continue
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			out.WriteString(ansiDim + "│ " + ansiReset + renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))) + "\n")
+			continue
+		}
+		out.WriteString(renderInline(line) + "\n")
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// renderInline formats the bold text, code spans and links in a line
+func renderInline(line string) string {
+	line = linkPattern.ReplaceAllString(line, "$1 "+ansiDim+"($2)"+ansiReset)
+	line = codeSpanPattern.ReplaceAllString(line, ansiCyan+"$1"+ansiReset)
+	return boldPattern.ReplaceAllString(line, ansiBold+"$1$2"+ansiReset)
+}
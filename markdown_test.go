@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{name: "heading", markdown: "## Steps ##", want: ansiBold + ansiUnderline + "Steps" + ansiReset},
+		{name: "minor heading", markdown: "### Logs", want: ansiBold + "Logs" + ansiReset},
+		{name: "not a heading", markdown: "#123 is related", want: "#123 is related"},
+		{name: "list", markdown: "- one\n  * two", want: "• one\n  • two"},
+		{name: "task list", markdown: "- [ ] todo\n- [x] done", want: "☐ todo\n☑ done"},
+		{name: "code fence", markdown: "```go\n# not a heading\n```\nafter", want: "    " + ansiDim + "# not a heading" + ansiReset + "\nafter"},
+		{name: "tilde fence", markdown: "~~~\n- not a list\n~~~", want: "    " + ansiDim + "- not a list" + ansiReset},
+		{name: "link", markdown: "see [the docs](https://example.com/docs \"Docs\")", want: "see the docs " + ansiDim + "(https://example.com/docs)" + ansiReset},
+		{name: "image", markdown: "![build.log](/uploads/abc/build.log)", want: "build.log " + ansiDim + "(/uploads/abc/build.log)" + ansiReset},
+		{name: "bold and code", markdown: "**must** run `make`", want: ansiBold + "must" + ansiReset + " run " + ansiCyan + "make" + ansiReset},
+		{name: "quote", markdown: "> it fails", want: ansiDim + "│ " + ansiReset + "it fails"},
+		{name: "rule", markdown: "---", want: ansiDim + strings.Repeat("─", 40) + ansiReset},
+	}
+	for _, tt := range tests {
+		if got := renderMarkdown(tt.markdown); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderToStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	defer func(f *os.File) { os.Stdout = f }(os.Stdout)
+	os.Stdout = w
+	if renderToStdout() {
+		t.Error("markdown is rendered to a pipe")
+	}
+}
+
+func TestPrintIssueRaw(t *testing.T) {
+	issue := &gitlab.Issue{IID: 1, Title: "Broken build", State: "opened", Description: "## Steps\n\n- run `make`\n"}
+	out := strings.Builder{}
+	printIssue(&out, issue, false)
+	if !strings.HasSuffix(out.String(), "\n## Steps\n\n- run `make`\n") || strings.Contains(out.String(), "\x1b[") {
+		t.Errorf("raw issue printed as %q, want the markdown as written", out.String())
+	}
+	out.Reset()
+	printIssue(&out, issue, true)
+	if strings.Contains(out.String(), "## Steps") || !strings.Contains(out.String(), "• run") {
+		t.Errorf("rendered issue printed as %q", out.String())
+	}
+}
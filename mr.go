@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/ktr0731/go-fuzzyfinder"
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// getTargetBranches lists the project's branches with the default branch first
+func (c gitlabClient) getTargetBranches(ctx context.Context, project *gitlab.Project) ([]string, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	branches := []string{project.DefaultBranch}
+	options := &gitlab.ListBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: 100}}
+	for {
+		var page []*gitlab.Branch
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			page, resp, err = c.gitlab.Branches.ListBranches(project.ID, options, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return branches, err
+		}
+		for _, branch := range page {
+			if branch.Name != project.DefaultBranch {
+				branches = append(branches, branch.Name)
+			}
+		}
+		if resp.NextPage == 0 {
+			return branches, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+func (c gitlabClient) createMergeRequestFromTemplate(ctx context.Context, repository *git.Repository, project *gitlab.Project, sourceBranch, targetBranch string, template issueTemplate) (*gitlab.MergeRequest, error) {
+	template.Content = expandTemplate(template.Content, repository, project)
+	seed, commentChar := seedTemplate(template)
+	path, content, err := editContent(repository, fmt.Sprintf("*_%s_%s_pre-submit-mr.md", project.Name, template.fileName()), seed)
+	if err != nil {
+		return nil, err
+	}
+	title, description, err := splitTitle(stripComments(content, commentChar))
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	if c.dryRun {
+		infof("Dry run, draft kept at %s", path)
+		return &gitlab.MergeRequest{
+			Title:        title,
+			Description:  description,
+			SourceBranch: sourceBranch,
+			TargetBranch: targetBranch,
+			State:        "dry run",
+		}, nil
+	}
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	var mr *gitlab.MergeRequest
+	_, err = c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		mr, resp, err = c.gitlab.MergeRequests.CreateMergeRequest(project.ID, &gitlab.CreateMergeRequestOptions{
+			Title:        gitlab.String(title),
+			Description:  gitlab.String(description),
+			SourceBranch: gitlab.String(sourceBranch),
+			TargetBranch: gitlab.String(targetBranch),
+		}, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create gitlab merge request: %w (%s)", err, path)
+	}
+	err = os.Remove(path) // remove file once sure of success
+	return mr, err
+}
+
+// createMergeRequest is the "mr" command, opening a merge request from the
+// current branch.
+func createMergeRequest(ctx context.Context, client gitlabClient, repo *git.Repository, project *gitlab.Project) error {
+	if repo == nil {
+		return fmt.Errorf("a git repository is needed to create a merge request")
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return fmt.Errorf("could not get current branch: %w", err)
+	}
+	if !head.Name().IsBranch() {
+		return fmt.Errorf("HEAD is not a branch")
+	}
+	sourceBranch := head.Name().Short()
+	branches, err := client.getTargetBranches(ctx, project)
+	if err != nil {
+		return fmt.Errorf("could not get branches: %w", err)
+	}
+	branchIdx, err := fuzzyfinder.Find(
+		branches,
+		func(i int) string {
+			return branches[i]
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to select target branch: %w", err)
+	}
+	templates, err := client.getTemplates(ctx, project, mergeRequestTemplatesDir)
+	if err != nil {
+		return fmt.Errorf("failed to get merge request templates for project: %w", err)
+	}
+	idx, err := fuzzyfinder.Find(
+		templates,
+		func(i int) string {
+			return templates[i].Name
+		},
+		templatePreview(templates),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to select template: %w", err)
+	}
+	infof("Selected template: %s", templates[idx].Name)
+	mr, err := client.createMergeRequestFromTemplate(ctx, repo, project, sourceBranch, branches[branchIdx], templates[idx])
+	if err != nil {
+		return fmt.Errorf("could not create merge request: %w", err)
+	}
+	if client.dryRun {
+		fmt.Printf("%s\n\n%s\n", mr.Title, strings.TrimSpace(mr.Description))
+		return nil
+	}
+	fmt.Println(mr.WebURL)
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// quiet suppresses informational logging, leaving warnings and errors
+var quiet bool
+
+// infof logs progress information unless quiet is set
+func infof(format string, v ...interface{}) {
+	if quiet {
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// issueOutput is the issue as printed by -output json
+type issueOutput struct {
+	IID       int      `json:"iid"`
+	WebURL    string   `json:"web_url"`
+	Title     string   `json:"title"`
+	Labels    []string `json:"labels"`
+	Milestone string   `json:"milestone,omitempty"`
+}
+
+// writeIssueJSON writes issue to w as a single JSON object
+func writeIssueJSON(w io.Writer, issue *gitlab.Issue) error {
+	out := issueOutput{
+		IID:    issue.IID,
+		WebURL: issue.WebURL,
+		Title:  issue.Title,
+		Labels: []string(issue.Labels),
+	}
+	if out.Labels == nil {
+		out.Labels = []string{}
+	}
+	if issue.Milestone != nil {
+		out.Milestone = issue.Milestone.Title
+	}
+	return json.NewEncoder(w).Encode(out)
+}
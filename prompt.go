@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	"golang.org/x/term"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on the terminal, returning def when the
+// answer is left blank.
+func confirm(question string, def bool) bool {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ", question, choices)
+	answer, _ := stdin.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+// stdinIsTerminal reports whether stdin is a terminal, rather than a pipe or
+// file an issue can be read from. Character devices such as /dev/null are not
+// terminals.
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readsIssueFromStdin reports whether the issue is read from stdin: like git
+// commit -F -, an issue piped in is created without the editor. Neither
+// -title nor -no-edit, which takes the template as it is, read it.
+func readsIssueFromStdin(title string, noEdit bool) bool {
+	return title == "" && !noEdit && !stdinIsTerminal()
+}
+
+// readStdin reads the rest of stdin
+func readStdin() ([]byte, error) {
+	content, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("could not read stdin: %w", err)
+	}
+	return content, nil
+}
+
+// prompt asks for a line of input on the terminal
+func prompt(question string) string {
+	fmt.Fprintf(os.Stderr, "%s ", question)
+	answer, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// errAborted is returned when a required selection is cancelled with Esc or
+// Ctrl-C, which exits quietly
+var errAborted = errors.New("aborted")
+
+// selected reports whether an optional selection of what was made. It is
+// skipped by cancelling it with Esc or Ctrl-C, while other errors are logged.
+func selected(what string, err error) bool {
+	if err == nil {
+		return true
+	}
+	if !errors.Is(err, fuzzyfinder.ErrAbort) {
+		log.Printf("Failed to select %s: %s", what, err)
+	}
+	return false
+}
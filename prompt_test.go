@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	"github.com/nsf/termbox-go"
+)
+
+func TestSelected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "made", err: nil, want: true},
+		{name: "aborted", err: fuzzyfinder.ErrAbort, want: false},
+		{name: "wrapped abort", err: fmt.Errorf("failed to select: %w", fuzzyfinder.ErrAbort), want: false},
+		{name: "failed", err: errors.New("no terminal"), want: false},
+	}
+	for _, tt := range tests {
+		if got := selected("labels", tt.err); got != tt.want {
+			t.Errorf("%s: selected(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAbortExitCode(t *testing.T) {
+	if got := exitCode(errAborted); got != exitAborted {
+		t.Errorf("exitCode(errAborted) = %d, want %d", got, exitAborted)
+	}
+	if got := exitCode(fmt.Errorf("could not create issue: %w", errAborted)); got != 130 {
+		t.Errorf("exitCode of a wrapped errAborted = %d, want 130", got)
+	}
+}
+
+// abortFinder makes the next selections with the fuzzyfinder be cancelled
+// with Esc
+func abortFinder() {
+	term := fuzzyfinder.UseMockedTerminal()
+	term.SetSize(60, 10)
+	term.SetEvents(termbox.Event{Type: termbox.EventKey, Key: termbox.KeyEsc})
+}
+
+func TestStdinIsTerminal(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	for _, f := range []*os.File{devNull, r} {
+		os.Stdin = f
+		if stdinIsTerminal() {
+			t.Errorf("%s is taken for a terminal", f.Name())
+		}
+	}
+}
+
+func TestReadsIssueFromStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = r
+	tests := []struct {
+		title  string
+		noEdit bool
+		want   bool
+	}{
+		{want: true},
+		{title: "Broken build", want: false},
+		{noEdit: true, want: false},
+		{title: "Broken build", noEdit: true, want: false},
+	}
+	for _, tt := range tests {
+		if got := readsIssueFromStdin(tt.title, tt.noEdit); got != tt.want {
+			t.Errorf("piped with title %q and no-edit %v: got %v, want %v", tt.title, tt.noEdit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// retryServerErrors replaces the go-gitlab retry policy so it only retries
+// server errors, leaving rate limiting to gitlabClient.retry.
+func retryServerErrors(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode >= 500, nil
+}
+
+// retryWait is how long gitlab asked us to wait before retrying a rate
+// limited request, from the Retry-After or RateLimit-Reset headers.
+func retryWait(resp *http.Response, attempt int) time.Duration {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return time.Until(t)
+		}
+	}
+	if v := resp.Header.Get("RateLimit-Reset"); v != "" {
+		if reset, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0))
+		}
+	}
+	return time.Second << attempt
+}
+
+// retry calls fn again while gitlab responds with 429 Too Many Requests, up
+// to c.retries times, waiting as long as gitlab asks between attempts.
+func (c gitlabClient) retry(ctx context.Context, fn func() (*gitlab.Response, error)) (*gitlab.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := fn()
+		if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= c.retries {
+			return resp, err
+		}
+		wait := retryWait(resp.Response, attempt)
+		log.Printf("Rate limited by gitlab, retrying in %s", wait.Round(time.Second))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func TestRetryWait(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		headers map[string]string
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{name: "Retry-After seconds", headers: map[string]string{"Retry-After": "3"}, min: 3 * time.Second, max: 3 * time.Second},
+		{name: "Retry-After date", headers: map[string]string{"Retry-After": now.Add(10 * time.Second).UTC().Format(http.TimeFormat)}, min: 8 * time.Second, max: 10 * time.Second},
+		{name: "RateLimit-Reset", headers: map[string]string{"RateLimit-Reset": strconv.FormatInt(now.Add(5*time.Second).Unix(), 10)}, min: 3 * time.Second, max: 5 * time.Second},
+		{name: "Retry-After before RateLimit-Reset", headers: map[string]string{"Retry-After": "2", "RateLimit-Reset": strconv.FormatInt(now.Add(time.Hour).Unix(), 10)}, min: 2 * time.Second, max: 2 * time.Second},
+		{name: "invalid Retry-After", headers: map[string]string{"Retry-After": "soon", "RateLimit-Reset": strconv.FormatInt(now.Add(5*time.Second).Unix(), 10)}, min: 3 * time.Second, max: 5 * time.Second},
+		{name: "no headers", attempt: 0, min: time.Second, max: time.Second},
+		{name: "no headers backs off", attempt: 2, min: 4 * time.Second, max: 4 * time.Second},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		for k, v := range tt.headers {
+			resp.Header.Set(k, v)
+		}
+		if got := retryWait(resp, tt.attempt); got < tt.min || got > tt.max {
+			t.Errorf("%s: waits %s, want %s to %s", tt.name, got, tt.min, tt.max)
+		}
+	}
+}
+
+// rateLimited responds 429 Too Many Requests to the first limited requests,
+// asking to retry after retryAfter, and then succeeds
+func rateLimited(t *testing.T, limited int, retryAfter string, requests *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// go-gitlab first asks the base URL for its rate limit
+		if r.URL.Path != "/api/v4/user" {
+			return
+		}
+		*requests++
+		if *requests <= limited {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			writeJSON(t, w, "", map[string]string{"message": "429 Too Many Requests"})
+			return
+		}
+		writeJSON(t, w, "", gitlab.User{ID: 1, Username: "me"})
+	})
+}
+
+// retriedRequest gets the current user with client.retry
+func retriedRequest(ctx context.Context, client gitlabClient) (*gitlab.Response, error) {
+	return client.retry(ctx, func() (*gitlab.Response, error) {
+		_, resp, err := client.gitlab.Users.CurrentUser(gitlab.WithContext(ctx))
+		return resp, err
+	})
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		limited      int
+		retries      int
+		wantRequests int
+		wantStatus   int
+	}{
+		{name: "not limited", limited: 0, retries: 3, wantRequests: 1, wantStatus: http.StatusOK},
+		{name: "limited then succeeds", limited: 2, retries: 3, wantRequests: 3, wantStatus: http.StatusOK},
+		{name: "gives up after the retries", limited: 10, retries: 2, wantRequests: 3, wantStatus: http.StatusTooManyRequests},
+		{name: "no retries", limited: 10, retries: 0, wantRequests: 1, wantStatus: http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		requests := 0
+		client := newTestClient(t, rateLimited(t, tt.limited, "0", &requests))
+		client.retries = tt.retries
+		resp, err := retriedRequest(context.Background(), client)
+		if requests != tt.wantRequests {
+			t.Errorf("%s: made %d requests, want %d", tt.name, requests, tt.wantRequests)
+		}
+		if resp == nil || resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: got response %v, want status %d", tt.name, resp, tt.wantStatus)
+		}
+		if (err != nil) != (tt.wantStatus != http.StatusOK) {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+}
+
+func TestRetryCancelledWhileWaiting(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, rateLimited(t, 10, "60", &requests))
+	client.retries = 3
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := retriedRequest(ctx, client)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("waited %s after the context was done", elapsed)
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want 1", requests)
+	}
+}
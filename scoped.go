@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// labelScope is the scope of a scoped label like priority::high, everything
+// before the last ::, or an empty string for a label without a scope
+func labelScope(name string) string {
+	i := strings.LastIndex(name, "::")
+	if i < 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// validScopedLabel checks name is a scoped label with a scope and a value
+func validScopedLabel(name string) error {
+	scope := labelScope(name)
+	if scope == "" || strings.TrimPrefix(name, scope+"::") == "" {
+		return fmt.Errorf("invalid -scoped %q, expected a scoped label like priority::high", name)
+	}
+	return nil
+}
+
+// sortLabelsByScope keeps the labels of each scope together, after those
+// without a scope, so they are next to each other when selecting labels
+func sortLabelsByScope(labels []issueLabel) {
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labelScope(labels[i].Name) < labelScope(labels[j].Name)
+	})
+}
+
+// withLabel adds label to labels, replacing any label of the same scope as
+// an issue can only have one label of each scope
+func withLabel(labels []issueLabel, label issueLabel) []issueLabel {
+	scope := labelScope(label.Name)
+	l := []issueLabel{}
+	for _, existing := range labels {
+		if existing.Name == label.Name || (scope != "" && labelScope(existing.Name) == scope) {
+			continue
+		}
+		l = append(l, existing)
+	}
+	return append(l, label)
+}
+
+// withLabelNames adds labels to the label names, replacing any of the same
+// scope
+func withLabelNames(names []string, labels []issueLabel) []string {
+	l := []issueLabel{}
+	for _, name := range names {
+		l = withLabel(l, issueLabel{Name: name})
+	}
+	for _, label := range labels {
+		l = withLabel(l, label)
+	}
+	names = []string{}
+	for _, label := range l {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+// ensureLabels finds each of names in the project's labels, creating those
+// which do not exist yet
+func (c gitlabClient) ensureLabels(ctx context.Context, project *gitlab.Project, names []string) ([]issueLabel, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	existing, err := c.getIssueLabels(ctx, project)
+	if err != nil {
+		return nil, fmt.Errorf("could not get labels: %w", err)
+	}
+	found := []issueLabel{}
+	for _, name := range names {
+		label, ok := issueLabel{}, false
+		for _, l := range existing {
+			if l.Name == name {
+				label, ok = l, true
+				break
+			}
+		}
+		if !ok {
+			infof("Creating label %s", name)
+			label, err = c.createLabel(ctx, project, name, defaultLabelColor)
+			if err != nil {
+				return nil, err
+			}
+		}
+		found = append(found, label)
+	}
+	return found, nil
+}
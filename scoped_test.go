@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels []string
+		label  string
+		want   []string
+	}{
+		{name: "no labels", labels: nil, label: "bug", want: []string{"bug"}},
+		{name: "unscoped", labels: []string{"bug"}, label: "p1", want: []string{"bug", "p1"}},
+		{name: "already given", labels: []string{"bug", "p1"}, label: "bug", want: []string{"p1", "bug"}},
+		{name: "replaces scope", labels: []string{"priority::low", "bug"}, label: "priority::high", want: []string{"bug", "priority::high"}},
+		{name: "other scope kept", labels: []string{"team::web", "priority::low"}, label: "priority::high", want: []string{"team::web", "priority::high"}},
+		{name: "nested scope", labels: []string{"team::web::lead", "team::web::dev"}, label: "team::web::ops", want: []string{"team::web::ops"}},
+		{name: "parent scope kept", labels: []string{"team::web"}, label: "team::web::ops", want: []string{"team::web", "team::web::ops"}},
+		{name: "unscoped keeps scoped", labels: []string{"priority::low"}, label: "priority", want: []string{"priority::low", "priority"}},
+	}
+	for _, tt := range tests {
+		labels := []issueLabel{}
+		for _, name := range tt.labels {
+			labels = append(labels, issueLabel{Name: name})
+		}
+		got := []string{}
+		for _, label := range withLabel(labels, issueLabel{Name: tt.label}) {
+			got = append(got, label.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
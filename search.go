@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// searchIssues is the "search" command, showing the selected issue whose
+// title or description matches the term in args.
+func searchIssues(ctx context.Context, client gitlabClient, project *gitlab.Project, args []string) error {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	allStates := flags.Bool("all-states", false, "include closed issues")
+	flags.Parse(args)
+	term := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if term == "" {
+		return usageErrorf("search needs a term, eg. gitlab search crash on save")
+	}
+	options := &gitlab.ListProjectIssuesOptions{Search: gitlab.String(term)}
+	if !*allStates {
+		options.State = gitlab.String("opened")
+	}
+	issues, err := client.getIssues(ctx, project, options)
+	if err != nil {
+		return fmt.Errorf("could not search issues: %w", err)
+	}
+	if len(issues) == 0 {
+		return fmt.Errorf("no issues match %q", term)
+	}
+	issue, err := selectIssue(issues)
+	if err != nil {
+		return err
+	}
+	printIssue(os.Stdout, issue, renderToStdout())
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func (c gitlabClient) getIssue(ctx context.Context, project *gitlab.Project, iid int) (*gitlab.Issue, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	issue, _, err := c.gitlab.Issues.GetIssue(project.ID, iid, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("could not get issue #%d: %w", iid, err)
+	}
+	return issue, nil
+}
+
+// parseIID parses an issue IID, with or without a leading #
+func parseIID(s string) (int, error) {
+	iid, err := strconv.Atoi(strings.TrimPrefix(s, "#"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid issue IID %q", s)
+	}
+	return iid, nil
+}
+
+// getIssueFromArgs fetches the issue whose IID is the first of args, or lets
+// the user select one of the project's open issues when args is empty.
+func (c gitlabClient) getIssueFromArgs(ctx context.Context, project *gitlab.Project, args []string) (*gitlab.Issue, error) {
+	if len(args) == 0 {
+		issues, err := c.getIssues(ctx, project, &gitlab.ListProjectIssuesOptions{State: gitlab.String("opened")})
+		if err != nil {
+			return nil, fmt.Errorf("could not list issues: %w", err)
+		}
+		return selectIssue(issues)
+	}
+	iid, err := parseIID(args[0])
+	if err != nil {
+		return nil, err
+	}
+	issue, err := c.getIssue(ctx, project, iid)
+	if err != nil {
+		return nil, err
+	}
+	return issue, nil
+}
+
+// printIssue prints the details of issue, with its description rendered for
+// the terminal when render is set
+func printIssue(w io.Writer, issue *gitlab.Issue, render bool) {
+	if issue.IID != 0 {
+		fmt.Fprintf(w, "#%d %s\n", issue.IID, issue.Title)
+	} else {
+		fmt.Fprintf(w, "%s\n", issue.Title)
+	}
+	fmt.Fprintf(w, "State:     %s\n", issue.State)
+	if issue.Confidential {
+		fmt.Fprintf(w, "Confidential\n")
+	}
+	if len(issue.Labels) > 0 {
+		fmt.Fprintf(w, "Labels:    %s\n", strings.Join(issue.Labels, ", "))
+	}
+	if issue.Milestone != nil {
+		fmt.Fprintf(w, "Milestone: %s\n", issue.Milestone.Title)
+	}
+	if len(issue.Assignees) > 0 {
+		assignees := []string{}
+		for _, a := range issue.Assignees {
+			assignees = append(assignees, "@"+a.Username)
+		}
+		fmt.Fprintf(w, "Assignees: %s\n", strings.Join(assignees, ", "))
+	}
+	if issue.Epic != nil {
+		fmt.Fprintf(w, "Epic:      &%d %s\n", issue.Epic.IID, issue.Epic.Title)
+	}
+	if issue.Weight != 0 {
+		fmt.Fprintf(w, "Weight:    %d\n", issue.Weight)
+	}
+	if issue.DueDate != nil {
+		fmt.Fprintf(w, "Due:       %s\n", issue.DueDate)
+	}
+	if issue.WebURL != "" {
+		fmt.Fprintf(w, "URL:       %s\n", issue.WebURL)
+	}
+	if issue.Description != "" {
+		description := strings.TrimSpace(issue.Description)
+		if render {
+			description = renderMarkdown(description)
+		}
+		fmt.Fprintf(w, "\n%s\n", description)
+	}
+}
+
+// showIssue is the "show" command, printing the details of an issue
+func showIssue(ctx context.Context, client gitlabClient, project *gitlab.Project, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	raw := flags.Bool("raw", false, "print the description as markdown instead of rendering it")
+	flags.Parse(args)
+	issue, err := client.getIssueFromArgs(ctx, project, flags.Args())
+	if err != nil {
+		return err
+	}
+	printIssue(os.Stdout, issue, !*raw && renderToStdout())
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// issueSummary names the milestone and assignees an issue is about to be
+// created with, which the create options only hold the IDs of, and the files
+// to attach to it
+type issueSummary struct {
+	Milestone string
+	// Assignees are usernames
+	Assignees []string
+	// Attachments are the paths of files uploaded and linked at the end of
+	// the description only once the issue is being created
+	Attachments []string
+}
+
+// print writes the fields of the issue titled title about to be created
+// with options, leaving out those not set
+func (s issueSummary) print(w io.Writer, title string, options gitlab.CreateIssueOptions) {
+	fmt.Fprintf(w, "Title:        %s\n", title)
+	if len(options.Labels) > 0 {
+		fmt.Fprintf(w, "Labels:       %s\n", strings.Join(options.Labels, ", "))
+	}
+	if s.Milestone != "" {
+		fmt.Fprintf(w, "Milestone:    %s\n", s.Milestone)
+	}
+	if len(s.Assignees) > 0 {
+		fmt.Fprintf(w, "Assignees:    @%s\n", strings.Join(s.Assignees, ", @"))
+	}
+	if options.DueDate != nil {
+		fmt.Fprintf(w, "Due:          %s\n", options.DueDate)
+	}
+	if options.Weight != nil {
+		fmt.Fprintf(w, "Weight:       %d\n", *options.Weight)
+	}
+	if options.Confidential != nil && *options.Confidential {
+		fmt.Fprintf(w, "Confidential: yes\n")
+	}
+	if len(s.Attachments) > 0 {
+		fmt.Fprintf(w, "Attachments:  %s\n", strings.Join(s.Attachments, ", "))
+	}
+}
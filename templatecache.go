@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// templateCacheTTL is how long cached project templates are used without
+// checking the branch they came from for new commits
+const templateCacheTTL = time.Hour
+
+// cachedTemplateFile is a template file fetched from a project
+type cachedTemplateFile struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// BlobID is the file's git blob, which changes with its content
+	BlobID  string `json:"blob_id"`
+	Content []byte `json:"content"`
+}
+
+// templateCache holds the templates fetched from a directory of a project on
+// a ref. It is stored under ~/.cache/gitlab/templates
+type templateCache struct {
+	// Commit is the commit the ref was at when the templates were fetched
+	Commit   string               `json:"commit"`
+	CachedAt time.Time            `json:"cached_at"`
+	Files    []cachedTemplateFile `json:"files"`
+}
+
+// templateCacheFile is the file caching the templates with one of extensions
+// in dir of the project on ref, named by a hash as refs and dirs can hold any
+// character
+func templateCacheFile(projectID int, dir, ref string, extensions []string) (string, error) {
+	cacheDir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%s\x00%s", projectID, dir, ref, strings.Join(extensions, ","))))
+	return filepath.Join(cacheDir, "templates", fmt.Sprintf("%x.json", key[:8])), nil
+}
+
+// loadTemplateCache reads the cache in path, an empty cache when it has not
+// been written yet
+func loadTemplateCache(path string) (templateCache, error) {
+	cache := templateCache{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, fmt.Errorf("could not read template cache %q: %w", path, err)
+	}
+	err = json.Unmarshal(b, &cache)
+	if err != nil {
+		return templateCache{}, fmt.Errorf("could not parse template cache %q: %w", path, err)
+	}
+	return cache, nil
+}
+
+func (t templateCache) save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("could not make dir %q: %w", filepath.Dir(path), err)
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("could not encode template cache: %w", err)
+	}
+	err = ioutil.WriteFile(path, b, 0600)
+	if err != nil {
+		return fmt.Errorf("could not write template cache %q: %w", path, err)
+	}
+	return nil
+}
+
+// fresh reports whether the cache can be used without checking the ref
+func (t templateCache) fresh() bool {
+	return !t.CachedAt.IsZero() && time.Since(t.CachedAt) < templateCacheTTL
+}
+
+// file returns the cached file at path with blobID, if there is one
+func (t templateCache) file(path, blobID string) (cachedTemplateFile, bool) {
+	for _, f := range t.Files {
+		if f.Path == path && f.BlobID == blobID {
+			return f, true
+		}
+	}
+	return cachedTemplateFile{}, false
+}
+
+// templates are the cached files as templates
+func (t templateCache) templates() []issueTemplate {
+	templates := []issueTemplate{}
+	for _, f := range t.Files {
+		templates = append(templates, issueTemplate{Name: f.Name, Content: f.Content, Form: isFormFile(f.Path)})
+	}
+	return templates
+}
+
+// branchCommit is the commit ID branch of the project is at
+func (c gitlabClient) branchCommit(ctx context.Context, project *gitlab.Project, branch string) (string, error) {
+	var b *gitlab.Branch
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		b, resp, err = c.gitlab.Branches.GetBranch(project.ID, branch, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not get branch %s: %w", branch, err)
+	}
+	if b.Commit == nil {
+		return "", nil
+	}
+	return b.Commit.ID, nil
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/ktr0731/go-fuzzyfinder"
+	"github.com/mitchellh/go-homedir"
+	gitlab "github.com/xanzy/go-gitlab"
+	"golang.org/x/sync/errgroup"
+)
+
+// template directories, both under ~/.config/gitlab locally and .gitlab in
+// the project repository
+const (
+	issueTemplatesDir        = "issue_templates"
+	mergeRequestTemplatesDir = "merge_request_templates"
+)
+
+type issueTemplate struct {
+	Name    string
+	Content []byte
+	// Form is set when Content is an issueForm rather than markdown
+	Form bool
+}
+
+// defaultTemplateExtensions are the file extensions of templates, unless set
+// by template_extensions in the config file
+var defaultTemplateExtensions = []string{".md", ".markdown", ".txt"}
+
+// templateName returns fileName without whichever of extensions it ends
+// with, or false if it has none of them.
+func templateName(fileName string, extensions []string) (string, bool) {
+	for _, ext := range extensions {
+		if strings.HasSuffix(fileName, ext) {
+			return strings.TrimSuffix(fileName, ext), true
+		}
+	}
+	return "", false
+}
+
+// fileName is the template's name made safe to use in a file name
+func (t issueTemplate) fileName() string {
+	return strings.ReplaceAll(t.Name, "/", "_")
+}
+
+// getLocalTemplates reads the templates with one of extensions in subdir of
+// templateDir, including those in nested directories which are named by their
+// path, eg. "bugs/crash [local]". An empty templateDir is the gitlab
+// directory of xdgConfigDir, where subdir is created if it is missing.
+func getLocalTemplates(templateDir, subdir string, extensions []string) ([]issueTemplate, error) {
+	templates := []issueTemplate{}
+	var localTemplateDir string
+	if templateDir != "" {
+		dir, err := homedir.Expand(templateDir)
+		if err != nil {
+			return templates, fmt.Errorf("could not expand %q: %w", templateDir, err)
+		}
+		localTemplateDir = filepath.Join(dir, subdir)
+		if _, err := os.Stat(localTemplateDir); os.IsNotExist(err) {
+			return templates, nil
+		}
+	} else {
+		configDir, err := xdgConfigDir()
+		if err != nil {
+			return templates, err
+		}
+		localTemplateDir = filepath.Join(configDir, "gitlab", subdir)
+		err = os.MkdirAll(localTemplateDir, os.ModePerm)
+		if err != nil {
+			return templates, fmt.Errorf("could not make dir %q: %w", localTemplateDir, err)
+		}
+	}
+	err := filepath.Walk(localTemplateDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("could not read %q: %w", path, err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if _, ok := templateName(info.Name(), extensions); !ok {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read file %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(localTemplateDir, path)
+		if err != nil {
+			return err
+		}
+		name, _ := templateName(filepath.ToSlash(rel), extensions)
+		templates = append(templates, issueTemplate{
+			Name:    name + " [local]",
+			Content: b,
+			Form:    isFormFile(info.Name()),
+		})
+		return nil
+	})
+	return templates, err
+}
+
+// getTemplates returns a BLANK template, then a DEFAULT template when the
+// project has a default description set, followed by the local, project and
+// shared templates found in subdir, eg. issueTemplatesDir, sorted by name.
+// Issue forms are included with the issue templates.
+func (c gitlabClient) getTemplates(ctx context.Context, project *gitlab.Project, subdir string) ([]issueTemplate, error) {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	templates := []issueTemplate{
+		{
+			Name:    "BLANK",
+			Content: []byte{},
+		},
+	}
+	extensions := c.templateExtensions
+	if subdir == issueTemplatesDir {
+		extensions = append(append([]string{}, extensions...), formExtensions...)
+	}
+	localTemplates, err := getLocalTemplates(c.templateDir, subdir, extensions)
+	if err != nil {
+		return templates, fmt.Errorf("could not get local templates: %w", err)
+	}
+	templates = append(templates, localTemplates...)
+	remoteTemplates, err := c.getRemoteTemplates(ctx, project, ".gitlab/"+subdir, extensions)
+	if err != nil {
+		return templates, err
+	}
+	templates = append(templates, remoteTemplates...)
+	if c.templateProject != "" {
+		templates = append(templates, c.getSharedTemplates(ctx, project, ".gitlab/"+subdir, extensions)...)
+	}
+	// keep BLANK first, so the order is the same on every run
+	others := templates[1:]
+	sort.SliceStable(others, func(i, j int) bool {
+		return others[i].Name < others[j].Name
+	})
+	description, err := c.getDefaultDescription(ctx, project, subdir)
+	if err != nil {
+		log.Printf("Not offering the project's default description: %s", describeErr(err))
+	}
+	if description != "" {
+		defaultTemplate := issueTemplate{Name: "DEFAULT", Content: []byte(description)}
+		templates = append(templates[:1], append([]issueTemplate{defaultTemplate}, others...)...)
+	}
+	return templates, nil
+}
+
+// defaultDescriptionFields are the project's default description of issues
+// and merge requests, which go-gitlab does not have
+type defaultDescriptionFields struct {
+	IssuesTemplate        string `json:"issues_template"`
+	MergeRequestsTemplate string `json:"merge_requests_template"`
+}
+
+// getDefaultDescription gets the default description set in the project's
+// settings for issues, or merge requests for merge_request_templates. It is
+// empty when none is set, or the instance does not support them.
+func (c gitlabClient) getDefaultDescription(ctx context.Context, project *gitlab.Project, subdir string) (string, error) {
+	fields := defaultDescriptionFields{}
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		req, err := c.gitlab.NewRequest(http.MethodGet, fmt.Sprintf("projects/%d", project.ID), nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+		if err != nil {
+			return nil, err
+		}
+		return c.gitlab.Do(req, &fields)
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not get project: %w", err)
+	}
+	if subdir == mergeRequestTemplatesDir {
+		return fields.MergeRequestsTemplate, nil
+	}
+	return fields.IssuesTemplate, nil
+}
+
+// findTemplate returns the template called name, where a local or shared
+// template may be named with or without its " [local]" or " [shared]"
+// suffix, local templates being found first.
+func findTemplate(templates []issueTemplate, name string) (issueTemplate, bool) {
+	for _, t := range templates {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	for _, suffix := range []string{" [local]", " [shared]"} {
+		for _, t := range templates {
+			if t.Name == name+suffix {
+				return t, true
+			}
+		}
+	}
+	return issueTemplate{}, false
+}
+
+// getSharedTemplates fetches the templates in dir of the template_project,
+// where an organisation can keep templates for all of its projects, named
+// like "bug [shared]". They are left out with a warning when the project can
+// not be found, and are not fetched twice when it is project itself.
+func (c gitlabClient) getSharedTemplates(ctx context.Context, project *gitlab.Project, dir string, extensions []string) []issueTemplate {
+	var shared *gitlab.Project
+	_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+		shared, resp, err = c.gitlab.Projects.GetProject(c.templateProject, nil, gitlab.WithContext(ctx))
+		return resp, err
+	})
+	if err != nil {
+		log.Printf("Not using templates from %s: %s", c.templateProject, describeErr(err))
+		return nil
+	}
+	if shared.ID == project.ID {
+		return nil
+	}
+	templates, err := c.getRemoteTemplates(ctx, shared, dir, extensions)
+	if err != nil {
+		log.Printf("Not using templates from %s: %s", c.templateProject, describeErr(err))
+		return nil
+	}
+	for i := range templates {
+		templates[i].Name += " [shared]"
+	}
+	return templates
+}
+
+// maxTemplateFetches is how many template files are fetched at once
+const maxTemplateFetches = 4
+
+// getRemoteTemplates fetches the templates with one of extensions in dir on
+// the project's default branch. They are cached, and used without asking gitlab until
+// templateCacheTTL has passed, then until the branch has new commits. Only
+// the templates whose content has changed are fetched again.
+func (c gitlabClient) getRemoteTemplates(ctx context.Context, project *gitlab.Project, dir string, extensions []string) ([]issueTemplate, error) {
+	templates := []issueTemplate{}
+	// a new, empty, project has no default branch to find templates on
+	if project.DefaultBranch == "" {
+		log.Printf("Not using templates from %s, it has no default branch yet", project.PathWithNamespace)
+		return templates, nil
+	}
+	cacheFile, err := templateCacheFile(project.ID, dir, project.DefaultBranch, extensions)
+	if err != nil {
+		log.Printf("Not caching templates: %s", err)
+	}
+	cache := templateCache{}
+	if cacheFile != "" && !c.refreshTemplates {
+		cache, err = loadTemplateCache(cacheFile)
+		if err != nil {
+			log.Printf("Ignoring template cache: %s", err)
+		}
+	}
+	if cache.fresh() {
+		return cache.templates(), nil
+	}
+	commit, err := c.branchCommit(ctx, project, project.DefaultBranch)
+	if err != nil {
+		log.Printf("Fetching templates again: %s", describeErr(err))
+	}
+	fetched := templateCache{Commit: commit, CachedAt: time.Now()}
+	if commit != "" && commit == cache.Commit {
+		fetched.Files = cache.Files
+		saveTemplateCache(cacheFile, fetched)
+		return fetched.templates(), nil
+	}
+	nodes := []*gitlab.TreeNode{}
+	treeOptions := &gitlab.ListTreeOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+		Ref:         gitlab.String(project.DefaultBranch),
+		Path:        gitlab.String(dir),
+	}
+	for {
+		var page []*gitlab.TreeNode
+		resp, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			page, resp, err = c.gitlab.Repositories.ListTree(project.ID, treeOptions, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return templates, fmt.Errorf("error fetching files from %s: %w", dir, err)
+		}
+		nodes = append(nodes, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		treeOptions.Page = resp.NextPage
+	}
+	files := []cachedTemplateFile{}
+	// missing are the indexes in files of those not cached
+	missing := []int{}
+	for _, node := range nodes {
+		name, ok := templateName(node.Name, extensions)
+		if !ok {
+			continue
+		}
+		if f, ok := cache.file(node.Path, node.ID); ok {
+			f.Name = name
+			files = append(files, f)
+			continue
+		}
+		missing = append(missing, len(files))
+		files = append(files, cachedTemplateFile{Name: name, Path: node.Path, BlobID: node.ID})
+	}
+	// those missing are fetched concurrently into their place, keeping the
+	// order of nodes
+	group, groupCtx := errgroup.WithContext(ctx)
+	fetching := make(chan struct{}, maxTemplateFetches)
+	for _, i := range missing {
+		f := &files[i]
+		group.Go(func() error {
+			select {
+			case fetching <- struct{}{}:
+			case <-groupCtx.Done():
+				return groupCtx.Err()
+			}
+			defer func() { <-fetching }()
+			var file *gitlab.File
+			_, err := c.retry(groupCtx, func() (resp *gitlab.Response, err error) {
+				file, resp, err = c.gitlab.RepositoryFiles.GetFile(
+					project.ID,
+					f.Path,
+					&gitlab.GetFileOptions{Ref: gitlab.String(project.DefaultBranch)},
+					gitlab.WithContext(groupCtx),
+				)
+				return resp, err
+			})
+			if err != nil {
+				return fmt.Errorf("error fetching file %s from %s: %w", f.Path, dir, err)
+			}
+			f.Content, err = base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				return fmt.Errorf("error decoding file %s from %s: %w", f.Path, dir, err)
+			}
+			return nil
+		})
+	}
+	err = group.Wait()
+	if err != nil {
+		return templates, err
+	}
+	fetched.Files = files
+	saveTemplateCache(cacheFile, fetched)
+	return fetched.templates(), nil
+}
+
+// saveTemplateCache writes templates to cacheFile, logging rather than
+// failing when it can not
+func saveTemplateCache(cacheFile string, templates templateCache) {
+	if cacheFile == "" {
+		return
+	}
+	err := templates.save(cacheFile)
+	if err != nil {
+		log.Printf("Could not cache templates: %s", err)
+	}
+}
+
+// expandTemplate replaces the {{branch}}, {{commit}}, {{project}} and
+// {{date}} placeholders in content. Placeholders which are unknown, or whose
+// value can not be found, are left as they are.
+func expandTemplate(content []byte, repo *git.Repository, project *gitlab.Project) []byte {
+	replacements := []string{
+		"{{project}}", project.Name,
+		"{{date}}", time.Now().Format("2006-01-02"),
+	}
+	if repo != nil {
+		head, err := repo.Head()
+		if err == nil {
+			if head.Name().IsBranch() {
+				replacements = append(replacements, "{{branch}}", head.Name().Short())
+			}
+			replacements = append(replacements, "{{commit}}", head.Hash().String()[:7])
+		}
+	}
+	return []byte(strings.NewReplacer(replacements...).Replace(string(content)))
+}
+
+// addFooter adds footer to the end of content after a blank line
+func addFooter(content, footer []byte) []byte {
+	if len(bytes.TrimSpace(footer)) == 0 {
+		return content
+	}
+	buf := bytes.Buffer{}
+	buf.Write(bytes.TrimRight(content, "\n"))
+	if buf.Len() > 0 {
+		buf.WriteString("\n\n")
+	}
+	buf.Write(footer)
+	return buf.Bytes()
+}
+
+// stripFooter removes footer from the end of description if it was left
+// unchanged
+func stripFooter(description string, footer []byte) string {
+	f := strings.TrimSpace(string(footer))
+	trimmed := strings.TrimRightFunc(description, unicode.IsSpace)
+	if f == "" || !strings.HasSuffix(trimmed, f) {
+		return description
+	}
+	return strings.TrimRightFunc(strings.TrimSuffix(trimmed, f), unicode.IsSpace) + "\n"
+}
+
+// commentChars are the candidates for the comment character, tried in order
+// like git's core.commentChar=auto, so markdown headings in a template are
+// not mistaken for comments.
+const commentChars = "#;@!$%^&|:"
+
+// pickCommentChar returns the first of commentChars that does not start any
+// line of content.
+func pickCommentChar(content []byte) byte {
+	for i := 0; i < len(commentChars); i++ {
+		used := false
+		for _, line := range bytes.Split(content, []byte("\n")) {
+			line = bytes.TrimLeft(line, " \t")
+			if len(line) > 0 && line[0] == commentChars[i] {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return commentChars[i]
+		}
+	}
+	return commentChars[0]
+}
+
+// stripComments removes every line starting with commentChar, ignoring
+// leading whitespace.
+func stripComments(content []byte, commentChar byte) []byte {
+	buf := bytes.Buffer{}
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && trimmed[0] == commentChar {
+			continue
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes()
+}
+
+const (
+	titleHelp   = "The first line is the title, the rest is the description."
+	commentHelp = "Lines starting with '%c' will be ignored."
+)
+
+// draftCommentChar finds the comment character used by seedTemplate in a
+// saved draft, defaulting to the first of commentChars.
+func draftCommentChar(content []byte) byte {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == len(titleHelp)+2 && bytes.HasSuffix(line, []byte(" "+titleHelp)) {
+			return line[0]
+		}
+	}
+	return commentChars[0]
+}
+
+// seedTemplate is the initial editor content for a template, leaving the
+// first line free for the title followed by help comments. The comment
+// character used is returned for stripComments.
+func seedTemplate(template issueTemplate) ([]byte, byte) {
+	commentChar := pickCommentChar(template.Content)
+	buf := bytes.Buffer{}
+	buf.WriteByte('\n')
+	fmt.Fprintf(&buf, "%c %s\n", commentChar, titleHelp)
+	fmt.Fprintf(&buf, "%c "+commentHelp+"\n", commentChar, commentChar)
+	buf.WriteByte('\n')
+	buf.Write(template.Content)
+	return buf.Bytes(), commentChar
+}
+
+// templatePreview shows the content of the highlighted template in the
+// fuzzyfinder, cut down to fit the preview window.
+func templatePreview(templates []issueTemplate) fuzzyfinder.Option {
+	return fuzzyfinder.WithPreviewWindow(func(i, width, height int) string {
+		if i < 0 {
+			return ""
+		}
+		lines := strings.Split(string(templates[i].Content), "\n")
+		// leave room for the preview window border
+		maxLines := height - 2
+		if maxLines < 1 {
+			maxLines = 1
+		}
+		if len(lines) > maxLines {
+			lines = append(lines[:maxLines-1], "…")
+		}
+		return strings.Join(lines, "\n")
+	})
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		commentChar byte
+		want        string
+	}{
+		{name: "comment lines", content: "title\n# help\nbody\n", commentChar: '#', want: "title\nbody\n"},
+		{name: "CRLF", content: "title\r\n# help\r\nbody\r\n", commentChar: '#', want: "title\r\nbody\r\n"},
+		{name: "leading whitespace", content: "title\n  # help\n\t# more\nbody\n", commentChar: '#', want: "title\nbody\n"},
+		{name: "other comment char", content: "title\n; help\n# heading\n", commentChar: ';', want: "title\n# heading\n"},
+		{name: "not at line start", content: "title has # in it\n", commentChar: '#', want: "title has # in it\n"},
+		{name: "no final newline", content: "title\n# help", commentChar: '#', want: "title\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(stripComments([]byte(tt.content), tt.commentChar))
+			if got != tt.want {
+				t.Errorf("stripComments(%q, %q) = %q, want %q", tt.content, tt.commentChar, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickCommentChar(t *testing.T) {
+	tests := []struct {
+		content string
+		want    byte
+	}{
+		{content: "", want: '#'},
+		{content: "text\n", want: '#'},
+		{content: "## Heading\n", want: ';'},
+		{content: "  # indented heading\n", want: ';'},
+		{content: "# heading\r\n; semicolon\r\n", want: '@'},
+	}
+	for _, tt := range tests {
+		if got := pickCommentChar([]byte(tt.content)); got != tt.want {
+			t.Errorf("pickCommentChar(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestDraftCommentChar(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    byte
+	}{
+		{name: "seeded", content: "\n; " + titleHelp + "\n; help\n## Heading\n", want: ';'},
+		{name: "CRLF", content: "\r\n@ " + titleHelp + "\r\n", want: '@'},
+		{name: "no help line", content: "title\nbody\n", want: '#'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := draftCommentChar([]byte(tt.content)); got != tt.want {
+				t.Errorf("draftCommentChar(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeedTemplateRoundTrip(t *testing.T) {
+	seed, commentChar := seedTemplate(issueTemplate{Name: "bug", Content: []byte("## Steps\n")})
+	if got := draftCommentChar(seed); got != commentChar {
+		t.Errorf("draftCommentChar of a seeded template = %q, want %q", got, commentChar)
+	}
+	if got, want := string(stripComments(seed, commentChar)), "\n\n## Steps\n"; got != want {
+		t.Errorf("stripped seeded template = %q, want %q", got, want)
+	}
+}
+
+func TestTemplateName(t *testing.T) {
+	tests := []struct {
+		fileName   string
+		extensions []string
+		want       string
+		ok         bool
+	}{
+		{fileName: "bug.md", extensions: defaultTemplateExtensions, want: "bug", ok: true},
+		{fileName: "bug.markdown", extensions: defaultTemplateExtensions, want: "bug", ok: true},
+		{fileName: "bug.txt", extensions: defaultTemplateExtensions, want: "bug", ok: true},
+		{fileName: "bugs/crash.md", extensions: defaultTemplateExtensions, want: "bugs/crash", ok: true},
+		{fileName: "notes.md.bak", extensions: defaultTemplateExtensions},
+		{fileName: "README", extensions: defaultTemplateExtensions},
+		{fileName: "bug.tmpl", extensions: defaultTemplateExtensions},
+		{fileName: "bug.tmpl", extensions: []string{".md", ".tmpl"}, want: "bug", ok: true},
+		{fileName: "bug.md", extensions: []string{".tmpl"}},
+		{fileName: "bug.yml", extensions: formExtensions, want: "bug", ok: true},
+		{fileName: "bug.yaml", extensions: formExtensions, want: "bug", ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := templateName(tt.fileName, tt.extensions)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("templateName(%q, %v) = %q, %v, want %q, %v", tt.fileName, tt.extensions, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// fakeTemplates serves project 1 with templates on its main branch
+type fakeTemplates struct {
+	// files maps the path of each file in the tree to its content
+	files map[string]string
+	// paths are the paths of files in the order listed
+	paths              []string
+	defaultDescription string
+	// treePage is how many files are listed per page of the tree
+	treePage int
+	// fetch is called as each file is fetched
+	fetch func(path string)
+}
+
+func (f *fakeTemplates) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, "", map[string]interface{}{"id": 1, "issues_template": f.defaultDescription})
+	})
+	mux.HandleFunc("/api/v4/projects/1/repository/branches/main", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, "", gitlab.Branch{Name: "main", Commit: &gitlab.Commit{ID: "c0ffee"}})
+	})
+	mux.HandleFunc("/api/v4/projects/1/repository/tree", func(w http.ResponseWriter, r *http.Request) {
+		perPage := f.treePage
+		if perPage == 0 {
+			perPage = len(f.paths)
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		start := (page - 1) * perPage
+		end := start + perPage
+		next := strconv.Itoa(page + 1)
+		if end >= len(f.paths) {
+			end, next = len(f.paths), ""
+		}
+		nodes := []gitlab.TreeNode{}
+		for _, p := range f.paths[start:end] {
+			nodes = append(nodes, gitlab.TreeNode{ID: "blob-" + p, Name: path.Base(p), Path: p, Type: "blob"})
+		}
+		writeJSON(t, w, next, nodes)
+	})
+	mux.HandleFunc("/api/v4/projects/1/repository/files/", func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/api/v4/projects/1/repository/files/")
+		content, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(t, w, "", map[string]string{"message": "404 File Not Found"})
+			return
+		}
+		if f.fetch != nil {
+			f.fetch(p)
+		}
+		writeJSON(t, w, "", gitlab.File{FilePath: p, Content: base64.StdEncoding.EncodeToString([]byte(content))})
+	})
+	return mux
+}
+
+// templateNames are the names of templates in order
+func templateNames(templates []issueTemplate) []string {
+	names := []string{}
+	for _, t := range templates {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestGetTemplatesOrder(t *testing.T) {
+	home := isolateHome(t)
+	localDir := filepath.Join(home, "templates", issueTemplatesDir)
+	if err := os.MkdirAll(localDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"zeta.md", "alpha.md"} {
+		if err := ioutil.WriteFile(filepath.Join(localDir, name), []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake := &fakeTemplates{
+		files: map[string]string{
+			".gitlab/issue_templates/middle.md": "middle",
+			".gitlab/issue_templates/Bug.md":    "bug",
+		},
+		paths:              []string{".gitlab/issue_templates/middle.md", ".gitlab/issue_templates/Bug.md"},
+		defaultDescription: "default description",
+	}
+	client := newTestClient(t, fake.handler(t))
+	client.templateDir = filepath.Join(home, "templates")
+	project := &gitlab.Project{ID: 1, DefaultBranch: "main"}
+	templates, err := client.getTemplates(context.Background(), project, issueTemplatesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BLANK", "DEFAULT", "Bug", "alpha [local]", "middle", "zeta [local]"}
+	if got := templateNames(templates); !reflect.DeepEqual(got, want) {
+		t.Errorf("got templates %q, want %q", got, want)
+	}
+	if string(templates[1].Content) != "default description" {
+		t.Errorf("DEFAULT template is %q, want the default description", templates[1].Content)
+	}
+
+	// without a default description BLANK is still first
+	fake.defaultDescription = ""
+	templates, err = client.getTemplates(context.Background(), project, issueTemplatesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"BLANK", "Bug", "alpha [local]", "middle", "zeta [local]"}
+	if got := templateNames(templates); !reflect.DeepEqual(got, want) {
+		t.Errorf("got templates %q, want %q", got, want)
+	}
+}
+
+func TestFooter(t *testing.T) {
+	footer := []byte("Found on main at abc1234\n")
+	tests := []struct {
+		name        string
+		content     string
+		withFooter  string
+		description string
+		want        string
+	}{
+		{name: "after content", content: "Title\n\nbody\n\n\n", withFooter: "Title\n\nbody\n\nFound on main at abc1234\n", description: "body\n\nFound on main at abc1234\n", want: "body\n"},
+		{name: "empty content", content: "", withFooter: "Found on main at abc1234\n", description: "Found on main at abc1234", want: "\n"},
+		{name: "footer changed", content: "Title\n", withFooter: "Title\n\nFound on main at abc1234\n", description: "body\n\nFound on fix at abc1234\n", want: "body\n\nFound on fix at abc1234\n"},
+		{name: "trailing blank lines", content: "Title", withFooter: "Title\n\nFound on main at abc1234\n", description: "body\nFound on main at abc1234\n \n\n", want: "body\n"},
+	}
+	for _, tt := range tests {
+		if got := string(addFooter([]byte(tt.content), footer)); got != tt.withFooter {
+			t.Errorf("%s: addFooter got %q, want %q", tt.name, got, tt.withFooter)
+		}
+		if got := stripFooter(tt.description, footer); got != tt.want {
+			t.Errorf("%s: stripFooter got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := string(addFooter([]byte("body\n"), []byte(" \n"))); got != "body\n" {
+		t.Errorf("a blank footer was added, got %q", got)
+	}
+	if got := stripFooter("body\n", nil); got != "body\n" {
+		t.Errorf("stripFooter without a footer got %q", got)
+	}
+}
+
+func TestGetRemoteTemplatesWithoutDefaultBranch(t *testing.T) {
+	isolateHome(t)
+	requests := 0
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	project := &gitlab.Project{ID: 1, PathWithNamespace: "g/empty"}
+	templates, err := client.getRemoteTemplates(context.Background(), project, issueTemplatesDir, client.templateExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 0 {
+		t.Errorf("got templates %q for a project without a default branch", templateNames(templates))
+	}
+	if requests != 0 {
+		t.Errorf("made %d requests for a project without a default branch, want none", requests)
+	}
+}
+
+func TestGetRemoteTemplatesPages(t *testing.T) {
+	isolateHome(t)
+	fake := &fakeTemplates{files: map[string]string{}, treePage: 2}
+	want := []string{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		p := ".gitlab/issue_templates/" + name + ".md"
+		fake.files[p] = name
+		fake.paths = append(fake.paths, p)
+		want = append(want, name)
+	}
+	client := newTestClient(t, fake.handler(t))
+	project := &gitlab.Project{ID: 1, DefaultBranch: "main"}
+	templates, err := client.getRemoteTemplates(context.Background(), project, issueTemplatesDir, client.templateExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := templateNames(templates); !reflect.DeepEqual(got, want) {
+		t.Errorf("got templates %q, want %q from every page of the tree", got, want)
+	}
+}
+
+func TestGetRemoteTemplatesFetchesConcurrently(t *testing.T) {
+	isolateHome(t)
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	fetched := []string{}
+	fake := &fakeTemplates{files: map[string]string{}, fetch: func(p string) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		fetched = append(fetched, p)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}}
+	want := []string{}
+	for i := 0; i < 3*maxTemplateFetches; i++ {
+		name := fmt.Sprintf("template-%02d", i)
+		p := ".gitlab/issue_templates/" + name + ".md"
+		fake.files[p] = name
+		fake.paths = append(fake.paths, p)
+		want = append(want, name)
+	}
+	// a stale cache still holds two of the files, which keep their place
+	cacheFile, err := templateCacheFile(1, issueTemplatesDir, "main", defaultTemplateExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := []string{fake.paths[1], fake.paths[5]}
+	stale := templateCache{Commit: "0ld"}
+	for _, p := range cached {
+		stale.Files = append(stale.Files, cachedTemplateFile{Path: p, BlobID: "blob-" + p, Content: []byte(fake.files[p])})
+	}
+	if err := stale.save(cacheFile); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newTestClient(t, fake.handler(t))
+	project := &gitlab.Project{ID: 1, DefaultBranch: "main"}
+	templates, err := client.getRemoteTemplates(context.Background(), project, issueTemplatesDir, client.templateExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := templateNames(templates); !reflect.DeepEqual(got, want) {
+		t.Errorf("got templates %q, want %q", got, want)
+	}
+	for _, tmpl := range templates {
+		if string(tmpl.Content) != tmpl.Name {
+			t.Errorf("template %s has content %q", tmpl.Name, tmpl.Content)
+		}
+	}
+	if maxInFlight > maxTemplateFetches {
+		t.Errorf("fetched %d files at once, want at most %d", maxInFlight, maxTemplateFetches)
+	}
+	if len(fetched) != len(want)-len(cached) {
+		t.Errorf("fetched %d files, want %d not cached", len(fetched), len(want)-len(cached))
+	}
+	for _, p := range fetched {
+		if p == cached[0] || p == cached[1] {
+			t.Errorf("fetched %s though it was cached", p)
+		}
+	}
+}
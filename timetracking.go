@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	gitlab "github.com/xanzy/go-gitlab"
+)
+
+// timePattern matches gitlab's time tracking durations like 2h, 30m or
+// 1w 2d 3h, with units of months, weeks, days, hours and minutes
+var timePattern = regexp.MustCompile(`^\s*(\d+(mo|w|d|h|m)\s*)+$`)
+
+// parseTimeDuration checks s, given to flag, is a gitlab duration
+func parseTimeDuration(flag, s string) (string, error) {
+	if s != "" && !timePattern.MatchString(s) {
+		return "", fmt.Errorf("invalid -%s %q, expected a duration like 30m, 2h or 1d 4h", flag, s)
+	}
+	return s, nil
+}
+
+// trackTime sets the time estimate and adds the time spent on issue, each
+// skipped when empty
+func (c gitlabClient) trackTime(ctx context.Context, project *gitlab.Project, issue *gitlab.Issue, estimate, spent string) error {
+	ctx, cancel := c.requestContext(ctx)
+	defer cancel()
+	if c.dryRun {
+		if estimate != "" || spent != "" {
+			infof("Dry run, not tracking time (estimate %q, spent %q)", estimate, spent)
+		}
+		return nil
+	}
+	if estimate != "" {
+		_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			_, resp, err = c.gitlab.Issues.SetTimeEstimate(project.ID, issue.IID, &gitlab.SetTimeEstimateOptions{Duration: gitlab.String(estimate)}, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return fmt.Errorf("could not set the time estimate: %w", err)
+		}
+	}
+	if spent != "" {
+		_, err := c.retry(ctx, func() (resp *gitlab.Response, err error) {
+			_, resp, err = c.gitlab.Issues.AddSpentTime(project.ID, issue.IID, &gitlab.AddSpentTimeOptions{Duration: gitlab.String(spent)}, gitlab.WithContext(ctx))
+			return resp, err
+		})
+		if err != nil {
+			return fmt.Errorf("could not add the time spent: %w", err)
+		}
+	}
+	return nil
+}
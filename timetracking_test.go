@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestParseTimeDuration(t *testing.T) {
+	tests := []struct {
+		s     string
+		valid bool
+	}{
+		{s: "", valid: true},
+		{s: "30m", valid: true},
+		{s: "2h", valid: true},
+		{s: "1w2d3h", valid: true},
+		{s: "1w 2d 3h 30m", valid: true},
+		{s: "1mo", valid: true},
+		{s: " 4h ", valid: true},
+		{s: "2", valid: false},
+		{s: "h", valid: false},
+		{s: "1.5h", valid: false},
+		{s: "2 hours", valid: false},
+		{s: "-1h", valid: false},
+		{s: "1y", valid: false},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeDuration("estimate", tt.s)
+		if tt.valid && (err != nil || got != tt.s) {
+			t.Errorf("parseTimeDuration(%q) = %q, %v, want it unchanged", tt.s, got, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("parseTimeDuration(%q) accepted an invalid duration", tt.s)
+		}
+	}
+}
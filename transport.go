@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// transportOptions configure the connection to gitlab
+type transportOptions struct {
+	// CACertFile holds PEM encoded certificates to trust as well as the
+	// system's
+	CACertFile string
+	// Insecure skips verifying certificates at all
+	Insecure bool
+	// Proxy is the URL of a proxy to use in place of HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY
+	Proxy string
+}
+
+// newTransport is the transport for requests to gitlab. Like
+// http.DefaultTransport it uses the proxy from the environment, unless
+// options give one.
+func newTransport(options transportOptions) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", options.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if options.CACertFile == "" && !options.Insecure {
+		return transport, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.Insecure}
+	if options.CACertFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(options.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificate %q: %w", options.CACertFile, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %q", options.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// debugTransport logs the method, URL and status of each request, for -debug
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+// secretParams are query parameters which can carry a token
+var secretParams = []string{"private_token", "access_token", "job_token"}
+
+func (t debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := *req.URL
+	u.User = nil
+	query := u.Query()
+	for _, p := range secretParams {
+		if query.Get(p) != "" {
+			query.Set(p, "REDACTED")
+		}
+	}
+	u.RawQuery = query.Encode()
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		log.Printf("%s %s: %s (%s)", req.Method, u.String(), err, elapsed)
+		return resp, err
+	}
+	log.Printf("%s %s: %s (%s)", req.Method, u.String(), resp.Status, elapsed)
+	return resp, nil
+}
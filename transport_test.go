@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// transportProxy is the proxy transport uses for a request to rawURL
+func transportProxy(t *testing.T, transport http.RoundTripper, rawURL string) string {
+	t.Helper()
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyURL, err := transport.(*http.Transport).Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxyURL == nil {
+		return ""
+	}
+	return proxyURL.String()
+}
+
+func TestNewTransportProxyFlag(t *testing.T) {
+	transport, err := newTransport(transportOptions{Proxy: "http://proxy.example:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transportProxy(t, transport, "https://gitlab.example.com/api/v4/projects"); got != "http://proxy.example:3128" {
+		t.Errorf("got proxy %q, want http://proxy.example:3128", got)
+	}
+	for _, proxy := range []string{"proxy.example:3128", "://"} {
+		if _, err := newTransport(transportOptions{Proxy: proxy}); err == nil {
+			t.Errorf("newTransport with proxy %q did not fail", proxy)
+		}
+	}
+}
+
+// TestNewTransportEnvironmentProxy runs itself in a new process, as net/http
+// reads the proxy environment only once
+func TestNewTransportEnvironmentProxy(t *testing.T) {
+	if os.Getenv("GITLAB_TEST_PROXY_ENV") == "" {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestNewTransportEnvironmentProxy$")
+		cmd.Env = append(os.Environ(),
+			"GITLAB_TEST_PROXY_ENV=1",
+			"HTTPS_PROXY=http://env-proxy.example:8080",
+			"https_proxy=http://env-proxy.example:8080",
+			"NO_PROXY=internal.example",
+			"no_proxy=internal.example",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s\n%s", err, out)
+		}
+		return
+	}
+	transport, err := newTransport(transportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transportProxy(t, transport, "https://gitlab.example.com/api/v4/projects"); got != "http://env-proxy.example:8080" {
+		t.Errorf("got proxy %q, want http://env-proxy.example:8080", got)
+	}
+	if got := transportProxy(t, transport, "https://internal.example/api/v4/projects"); got != "" {
+		t.Errorf("got proxy %q for a NO_PROXY host, want none", got)
+	}
+	transport, err = newTransport(transportOptions{Proxy: "http://proxy.example:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transportProxy(t, transport, "https://gitlab.example.com/api/v4/projects"); got != "http://proxy.example:3128" {
+		t.Errorf("got proxy %q, want -proxy to override the environment", got)
+	}
+}
+
+func TestDebugTransportRedactsTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	logged := bytes.Buffer{}
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	client := &http.Client{Transport: debugTransport{base: http.DefaultTransport}}
+	withUser := strings.Replace(server.URL, "://", "://user:secret-password@", 1)
+	req, err := http.NewRequest("GET", withUser+"/api/v4/projects?search=p&private_token=secret-query&job_token=secret-job-query", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", "secret-private")
+	req.Header.Set("JOB-TOKEN", "secret-job")
+	req.Header.Set("Authorization", "Bearer secret-bearer")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	out := logged.String()
+	if strings.Contains(out, "secret") {
+		t.Errorf("debug output shows a token: %s", out)
+	}
+	for _, want := range []string{"GET ", "/api/v4/projects?", "search=p", "private_token=REDACTED", "job_token=REDACTED", "404 Not Found"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("debug output %q does not show %q", out, want)
+		}
+	}
+}
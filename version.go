@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// set when building a release with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.date=2006-01-02"
+var version, commit, date string
+
+// printVersion writes the build's version, commit and date
+func printVersion(w io.Writer) {
+	v := version
+	if v == "" {
+		v = "dev"
+	}
+	fmt.Fprintf(w, "gitlab %s", v)
+	if commit != "" {
+		fmt.Fprintf(w, " (%s)", commit)
+	}
+	if date != "" {
+		fmt.Fprintf(w, " built %s", date)
+	}
+	fmt.Fprintln(w)
+}